@@ -1,52 +1,250 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/rohits-web03/obscyra/internal/api"
+	"github.com/rohits-web03/obscyra/internal/api/middleware"
+	"github.com/rohits-web03/obscyra/internal/api/services"
+	"github.com/rohits-web03/obscyra/internal/app"
 	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/rohits-web03/obscyra/internal/health"
+	"github.com/rohits-web03/obscyra/internal/lifecycle"
+	"github.com/rohits-web03/obscyra/internal/logging"
+	"github.com/rohits-web03/obscyra/internal/mailer"
+	"github.com/rohits-web03/obscyra/internal/metrics"
 	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/telemetry"
+	"github.com/rohits-web03/obscyra/internal/throttle"
+	"github.com/rohits-web03/obscyra/internal/tokens"
+	"gorm.io/gorm"
 )
 
 func main() {
-	// Connect to database
-	repositories.ConnectDatabase()
-	// Initialize R2
-	err := repositories.InitR2(
-		config.Envs.R2.AccessKeyID,
-		config.Envs.R2.SecretAccessKey,
-		config.Envs.R2.AccountID,
-		config.Envs.R2.BucketName,
-		config.Envs.R2.Region,
-	)
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
 	if err != nil {
-		log.Fatalf("failed to init R2: %v", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-
-	const defaultPort = "8080"
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	if *printConfig {
+		out, err := cfg.YAML()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+		return
 	}
+	logging.Setup(cfg.LogLevel)
+	slog.Info("Configuration loaded", "file", *configFile, "config", cfg)
+	for _, warning := range cfg.Warnings() {
+		slog.Warn("Insecure configuration: " + warning)
+	}
+
+	// Cancelled on SIGINT/SIGTERM to begin a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	mux := api.SetupRouter()
+	if flag.Arg(0) == "migrate" {
+		os.Exit(runMigrate(ctx, cfg, flag.Args()[1:]))
+	}
 
+	port := cfg.Port
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", port),
-		Handler: mux,
+		Addr: fmt.Sprintf(":%s", port),
 		// Timeouts prevent resource exhaustion from slow clients
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	serverErr := make(chan error, 1)
+
+	// Filled in by the components below as they start
+	application := &app.App{
+		Config:  cfg,
+		Logger:  slog.Default(),
+		Metrics: metrics.New(),
+		Health:  health.New(),
 	}
 
-	log.Printf("Starting Obscyra server on port: %s", port)
+	// Only used to build the stores below; handlers go through Repos
+	var db *gorm.DB
+
+	// Subsystems start in this order and stop in reverse, so the HTTP server
+	// stops first and the database and tracing last
+	lc := lifecycle.New()
+	var shutdownTracing telemetry.ShutdownFunc
+	lc.Add(lifecycle.Component{
+		Name: "tracing",
+		Start: func(ctx context.Context) (err error) {
+			shutdownTracing, err = telemetry.Init(ctx, cfg.Tracing)
+			return err
+		},
+		// Flushes the spans recorded while everything else shut down
+		Stop: func(ctx context.Context) error {
+			return shutdownTracing(ctx)
+		},
+	})
+	lc.Add(lifecycle.Component{
+		Name: "session keys",
+		Start: func(ctx context.Context) (err error) {
+			application.Tokens, err = tokens.New(cfg.JWT, cfg.JWTSecret)
+			if err == nil && application.Tokens.KeyID() != "" {
+				slog.Info("Signing session tokens", "kid", application.Tokens.KeyID())
+			}
+			return err
+		},
+	})
+	lc.Add(lifecycle.Component{
+		Name: "database",
+		Start: func(ctx context.Context) (err error) {
+			db, err = repositories.ConnectDatabase(cfg.DB_URL)
+			if err != nil {
+				return err
+			}
+			if err := prepareSchema(ctx, db, cfg.AutoMigrate); err != nil {
+				return err
+			}
+			application.Repos = repositories.NewPostgresRepositories(db)
+			// Expose database pool statistics
+			return application.Metrics.RegisterDB(db)
+		},
+		Stop: func(ctx context.Context) error {
+			return repositories.CloseDatabase(db)
+		},
+	})
+	lc.Add(lifecycle.Component{
+		Name: "storage",
+		Start: func(ctx context.Context) error {
+			if cfg.Storage.Driver == "local" {
+				local, err := repositories.NewLocalStorage(cfg.Storage.LocalDir, cfg.BaseURL, cfg.JWTSecret, cfg.Transfers.MaxUploadSize, application.Metrics)
+				if err != nil {
+					return err
+				}
+				application.Storage = local
+				return nil
+			}
+			application.Storage = repositories.NewR2Storage(cfg.R2, application.Metrics)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if local, ok := application.Storage.(*repositories.LocalStorage); ok {
+				return local.Close()
+			}
+			return nil
+		},
+	})
+	lc.Add(lifecycle.Component{
+		Name: "login throttling",
+		Start: func(ctx context.Context) (err error) {
+			application.Logins, err = throttle.NewLogins(cfg.LoginThrottle, db, application.Logger)
+			return err
+		},
+	})
+	lc.Add(lifecycle.Component{
+		Name: "rate limiting",
+		Start: func(ctx context.Context) (err error) {
+			application.TrustedProxies, err = middleware.ParseTrustedProxies(cfg.RateLimit.TrustedProxies)
+			if err != nil {
+				return err
+			}
+			application.RateLimits, err = middleware.NewRateLimitStore(cfg.RateLimit, db, application.Logger)
+			return err
+		},
+	})
+	lc.Add(lifecycle.Component{
+		Name: "mailer",
+		Start: func(ctx context.Context) (err error) {
+			application.Mailer, err = mailer.New(cfg.Mail)
+			return err
+		},
+		// Let verification and reset emails queued by requests go out
+		Stop: func(ctx context.Context) error {
+			return application.Mailer.Wait(ctx)
+		},
+	})
+	lc.Add(lifecycle.Component{
+		Name: "identity providers",
+		Start: func(ctx context.Context) error {
+			application.Providers = services.NewIdentityProviders(cfg.Google, cfg.OIDCProviders)
+			return nil
+		},
+	})
+	lc.Add(lifecycle.Component{
+		Name: "http server",
+		Start: func(ctx context.Context) error {
+			// Dependencies checked by the readiness probe
+			application.Health.Register("database", func(ctx context.Context) error {
+				return repositories.PingDB(ctx, db)
+			})
+			application.Health.Register("storage", application.Storage.Ping)
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Could not listen on port %s: %v", port, err)
+			server.Handler = api.SetupRouter(application)
+
+			// Bind before returning so a port in use fails startup
+			ln, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return err
+			}
+			slog.Info("Starting Obscyra server", "port", port)
+			go func() {
+				if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					serverErr <- err
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			// Report not ready and keep serving for a moment so load
+			// balancers stop sending new requests before we stop accepting
+			application.Health.SetDraining()
+			if delay := cfg.Shutdown.Delay; delay > 0 {
+				slog.Info("Draining before shutdown", "delay", delay.String())
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+				}
+			}
+			// Waits for in-flight requests, such as upload completions
+			return server.Shutdown(ctx)
+		},
+	})
+
+	if err := lc.Start(ctx); err != nil {
+		logging.Fatal("Failed to start", "error", err)
+	}
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received")
+	case err := <-serverErr:
+		slog.Error("Server failed", "error", err)
+		exitCode = 1
 	}
+	// A second signal kills the process immediately
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+	defer cancel()
+	if err := lc.Stop(shutdownCtx); err != nil {
+		exitCode = 1
+	}
+
+	slog.Info("Server stopped")
+	os.Exit(exitCode)
 }
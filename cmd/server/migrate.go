@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+	"gorm.io/gorm"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down [N]    revert the last N migrations (default 1)
+  status      list migrations and when they were applied`
+
+// runMigrate implements the migrate subcommand and returns the exit code.
+func runMigrate(ctx context.Context, cfg config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	db, err := repositories.ConnectDatabase(cfg.DB_URL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer repositories.CloseDatabase(db)
+
+	switch args[0] {
+	case "up":
+		err = repositories.MigrateUp(ctx, db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				fmt.Fprintf(os.Stderr, "invalid number of migrations %q\n", args[1])
+				return 2
+			}
+		}
+		err = repositories.MigrateDown(ctx, db, steps)
+	case "status":
+		err = printMigrationStatus(ctx, db)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printMigrationStatus(ctx context.Context, db *gorm.DB) error {
+	states, err := repositories.MigrationStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range states {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		name := s.Name
+		if s.Unknown {
+			name = "(unknown to this build)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, name, applied)
+	}
+	return w.Flush()
+}
+
+// prepareSchema applies pending migrations on startup, or refuses to start
+// against an outdated schema when auto-migration is disabled.
+func prepareSchema(ctx context.Context, db *gorm.DB, autoMigrate bool) error {
+	if autoMigrate {
+		return repositories.MigrateUp(ctx, db)
+	}
+
+	pending, err := repositories.PendingMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return errors.New("database schema is out of date, run `server migrate up` before starting")
+	}
+	return nil
+}
@@ -15,6 +15,308 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "Returns the public keys session JWTs are signed with as a JSON Web Key Set (RFC 7517), so other services can verify them. Tokens name their key in the kid header. The set is empty when tokens are signed with the shared HS256 secret.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Session token verification keys",
+                "responses": {
+                    "200": {
+                        "description": "Key set",
+                        "schema": {
+                            "$ref": "#/definitions/tokens.JWKS"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/oidc/{provider}/callback": {
+            "get": {
+                "description": "Exchanges the authorization code, verifies the ID token and signs the user in, then redirects to return_to or the frontend. Failures redirect to the frontend with an error code in the error query parameter.",
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "OIDC callback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "OAuth state",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Authorization code",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "307": {
+                        "description": "Redirect to frontend"
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/oidc/{provider}/login": {
+            "get": {
+                "description": "Redirects the browser to the identity provider's authorization endpoint. Use redirect=register to create an account on callback. Failures redirect to the frontend with an error code in the error query parameter.",
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Start OIDC login",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "login (default) or register",
+                        "name": "redirect",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Frontend path or allowed URL to return to after signing in",
+                        "name": "return_to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "307": {
+                        "description": "Redirect to identity provider"
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/password/forgot": {
+            "post": {
+                "description": "Emails a single-use password reset link if an account exists for the address. The response is the same whether or not it does.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Request a password reset",
+                "parameters": [
+                    {
+                        "description": "Account email",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.EmailInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reset email sent if the account exists",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/password/reset": {
+            "post": {
+                "description": "Sets a new password using a reset token and signs out all sessions. Because the private key is wrapped with the password, the request must either carry the existing private key re-wrapped with the new password, or set resetKeys with a new key pair, which marks data encrypted for the old public key as unrecoverable.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Reset password",
+                "parameters": [
+                    {
+                        "description": "Reset payload",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ResetPasswordInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Password reset successfully",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or invalid/expired token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/verify-email": {
+            "post": {
+                "description": "Consumes a single-use verification token sent by email and marks the address as verified.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Verify email address",
+                "parameters": [
+                    {
+                        "description": "Verification token",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.VerifyEmailInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Email verified successfully",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid or expired token",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/verify-email/resend": {
+            "post": {
+                "description": "Sends a new verification link if an unverified account exists for the address. The response is the same whether or not it does.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Resend verification email",
+                "parameters": [
+                    {
+                        "description": "Account email",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.EmailInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Verification email sent if the account exists",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/csrf-token": {
+            "get": {
+                "description": "Issues a CSRF token for the signed in user and sets it in the csrf_token cookie. Browser clients send it in the X-CSRF-Token header of every POST, PUT, PATCH and DELETE authenticated with the session cookie. Fetch a new token after signing in.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Get a CSRF token",
+                "responses": {
+                    "200": {
+                        "description": "CSRF token issued",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/files/complete": {
             "post": {
                 "description": "Verifies uploaded files on R2, stores file metadata, and registers the upload session in the database. Each transfer is valid for 1 hour and limited to 100MB for anonymous uploads.",
@@ -71,6 +373,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.Payload"
                         }
                     },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
                     "500": {
                         "description": "Database error",
                         "schema": {
@@ -82,7 +390,7 @@ const docTemplate = `{
         },
         "/api/v1/files/presign": {
             "post": {
-                "description": "Accepts a list of files (name and size), validates the total size, and returns presigned PUT URLs for each file. Each upload session is identified by a unique token.",
+                "description": "Accepts a list of 1 to 100 files (name and size), validates the total size, and returns presigned PUT URLs for each file. Each upload session is identified by a unique token.",
                 "consumes": [
                     "application/json"
                 ],
@@ -103,12 +411,17 @@ const docTemplate = `{
                             "type": "array",
                             "items": {
                                 "type": "object",
+                                "required": [
+                                    "filename"
+                                ],
                                 "properties": {
                                     "filename": {
-                                        "type": "string"
+                                        "type": "string",
+                                        "maxLength": 255
                                     },
                                     "size": {
-                                        "type": "integer"
+                                        "type": "integer",
+                                        "minimum": 0
                                     }
                                 }
                             }
@@ -117,7 +430,312 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Presigned URLs generated successfully",
+                        "description": "Presigned URLs generated successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Payload"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handlers.PresignResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or size limit exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "405": {
+                        "description": "Method not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to generate presigned URL",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/me/identities": {
+            "get": {
+                "description": "Returns the external identity providers linked to the signed in account and whether a password is set.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Account"
+                ],
+                "summary": "List linked identities",
+                "responses": {
+                    "200": {
+                        "description": "Identities retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/me/identities/{provider}": {
+            "delete": {
+                "description": "Removes a linked provider identity. The last sign-in method of an account can't be removed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Account"
+                ],
+                "summary": "Unlink an identity provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Identity unlinked successfully",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "404": {
+                        "description": "Identity not linked",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "409": {
+                        "description": "Cannot remove the only sign-in method",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/me/identities/{provider}/link": {
+            "get": {
+                "description": "Redirects the signed in user's browser to the provider. After consent the provider identity is linked to the account and the browser is sent to return_to or the frontend settings page.",
+                "tags": [
+                    "Account"
+                ],
+                "summary": "Link an identity provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name (google or a configured OIDC provider)",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Frontend path or allowed URL to return to after linking",
+                        "name": "return_to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "307": {
+                        "description": "Redirect to identity provider"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/me/password": {
+            "post": {
+                "description": "Verifies the current password, then stores the new password hash and the re-wrapped private key in one transaction. All other sessions are signed out; the current session receives a fresh cookie.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Account"
+                ],
+                "summary": "Change password",
+                "parameters": [
+                    {
+                        "description": "Password change payload",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ChangePasswordInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Password changed successfully",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or current password is incorrect",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many wrong passwords, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/me/tokens": {
+            "get": {
+                "description": "GET lists the personal access tokens of the signed in account. POST creates one; the token is only returned in this response. Clients send it as \"Authorization: Bearer \u003ctoken\u003e\" and may use the routes its scopes cover.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Account"
+                ],
+                "summary": "List or create personal access tokens",
+                "parameters": [
+                    {
+                        "description": "Token to create (POST only)",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateAccessTokenInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Access tokens retrieved successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Payload"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/handlers.AccessTokenInfo"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "201": {
+                        "description": "Access token created successfully",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or too many tokens",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires a session",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "GET lists the personal access tokens of the signed in account. POST creates one; the token is only returned in this response. Clients send it as \"Authorization: Bearer \u003ctoken\u003e\" and may use the routes its scopes cover.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Account"
+                ],
+                "summary": "List or create personal access tokens",
+                "parameters": [
+                    {
+                        "description": "Token to create (POST only)",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateAccessTokenInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Access tokens retrieved successfully",
                         "schema": {
                             "allOf": [
                                 {
@@ -127,27 +745,89 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/handlers.PresignResponse"
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/handlers.AccessTokenInfo"
+                                            }
                                         }
                                     }
                                 }
                             ]
                         }
                     },
+                    "201": {
+                        "description": "Access token created successfully",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
                     "400": {
-                        "description": "Invalid input or size limit exceeded",
+                        "description": "Invalid input or too many tokens",
                         "schema": {
                             "$ref": "#/definitions/utils.Payload"
                         }
                     },
-                    "405": {
-                        "description": "Method not allowed",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/utils.Payload"
                         }
                     },
-                    "500": {
-                        "description": "Failed to generate presigned URL",
+                    "403": {
+                        "description": "Requires a session",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "413": {
+                        "description": "Request body too large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/me/tokens/{id}": {
+            "delete": {
+                "description": "Deletes a personal access token of the signed in account. Requests using it fail from then on.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Account"
+                ],
+                "summary": "Revoke a personal access token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Access token ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Access token revoked successfully",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "403": {
+                        "description": "Requires a session",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
+                    "404": {
+                        "description": "Access token not found",
                         "schema": {
                             "$ref": "#/definitions/utils.Payload"
                         }
@@ -190,6 +870,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.Payload"
                         }
                     },
+                    "403": {
+                        "description": "Not a recipient of this transfer",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
                     "404": {
                         "description": "Invalid or expired share link",
                         "schema": {
@@ -247,6 +933,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.Payload"
                         }
                     },
+                    "403": {
+                        "description": "Not a recipient of this transfer",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    },
                     "404": {
                         "description": "File not found or invalid share link",
                         "schema": {
@@ -261,34 +953,215 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/livez": {
+            "get": {
+                "description": "Reports that the process is running and able to serve HTTP. It doesn't check dependencies, so a failing database never gets the server restarted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "Alive",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Payload"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Checks the database and object storage and reports the result of each. Returns 503 when a dependency is unavailable or the server is shutting down.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "Ready",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Payload"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/health.ReadinessReport"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "503": {
+                        "description": "Not ready",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Payload"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/health.ReadinessReport"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
+        "handlers.AccessTokenInfo": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "hint": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.ChangePasswordInput": {
+            "type": "object",
+            "required": [
+                "currentPassword",
+                "encryptedPrivateKey",
+                "newPassword"
+            ],
+            "properties": {
+                "currentPassword": {
+                    "type": "string",
+                    "maxLength": 1024
+                },
+                "encryptedPrivateKey": {
+                    "description": "EncryptedPrivateKey is the existing private key re-wrapped client side\nwith a key derived from the new password.",
+                    "type": "string",
+                    "maxLength": 16384
+                },
+                "newPassword": {
+                    "type": "string"
+                }
+            }
+        },
         "handlers.CompleteUploadInput": {
             "type": "object",
+            "required": [
+                "files",
+                "token"
+            ],
             "properties": {
                 "files": {
+                    "description": "services.MaxTransferFiles",
                     "type": "array",
+                    "maxItems": 100,
                     "items": {
                         "type": "object",
+                        "required": [
+                            "filename",
+                            "key"
+                        ],
                         "properties": {
                             "contentType": {
-                                "type": "string"
+                                "type": "string",
+                                "maxLength": 255
                             },
                             "filename": {
-                                "type": "string"
+                                "type": "string",
+                                "maxLength": 255
                             },
                             "key": {
-                                "type": "string"
+                                "type": "string",
+                                "maxLength": 1024
                             },
                             "size": {
-                                "type": "integer"
+                                "type": "integer",
+                                "minimum": 0
                             }
                         }
                     }
                 },
+                "recipientKeys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.RecipientInput"
+                    }
+                },
                 "token": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 256
+                }
+            }
+        },
+        "handlers.CreateAccessTokenInput": {
+            "type": "object",
+            "required": [
+                "name",
+                "scopes"
+            ],
+            "properties": {
+                "expiresInDays": {
+                    "description": "ExpiresInDays is the lifetime of the token; 0 creates a token that\ndoesn't expire",
+                    "type": "integer",
+                    "maximum": 365,
+                    "minimum": 0
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "scopes": {
+                    "description": "Scopes granted to the token, e.g. \"transfers:write\" and \"inbox:read\"",
+                    "type": "array",
+                    "maxItems": 10,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.EmailInput": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "maxLength": 254
                 }
             }
         },
@@ -320,13 +1193,319 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.RecipientInput": {
+            "type": "object",
+            "required": [
+                "encryptedKey",
+                "publicKey"
+            ],
+            "properties": {
+                "encryptedKey": {
+                    "description": "The encrypted AES key",
+                    "type": "string",
+                    "maxLength": 16384
+                },
+                "publicKey": {
+                    "description": "Used to find the User ID",
+                    "type": "string",
+                    "maxLength": 8192
+                }
+            }
+        },
+        "handlers.ResetPasswordInput": {
+            "type": "object",
+            "required": [
+                "encryptedPrivateKey",
+                "password",
+                "token"
+            ],
+            "properties": {
+                "encryptedPrivateKey": {
+                    "description": "EncryptedPrivateKey is the private key wrapped with the new password.\nWithout ResetKeys it must be the existing key pair re-wrapped client side.",
+                    "type": "string",
+                    "maxLength": 16384
+                },
+                "password": {
+                    "type": "string"
+                },
+                "publicKey": {
+                    "type": "string",
+                    "maxLength": 8192
+                },
+                "resetKeys": {
+                    "description": "ResetKeys replaces the key pair with PublicKey/EncryptedPrivateKey and\nmarks data encrypted for the old public key as unrecoverable.",
+                    "type": "boolean"
+                },
+                "token": {
+                    "type": "string",
+                    "maxLength": 256
+                }
+            }
+        },
+        "handlers.VerifyEmailInput": {
+            "type": "object",
+            "required": [
+                "token"
+            ],
+            "properties": {
+                "token": {
+                    "type": "string",
+                    "maxLength": 256
+                }
+            }
+        },
+        "health.CheckResult": {
+            "type": "object",
+            "properties": {
+                "durationMs": {
+                    "type": "number"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "\"ok\" or \"failed\"",
+                    "type": "string"
+                }
+            }
+        },
+        "health.ReadinessReport": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/health.CheckResult"
+                    }
+                },
+                "status": {
+                    "description": "\"ready\", \"not_ready\" or \"draining\"",
+                    "type": "string"
+                }
+            }
+        },
+        "tokens.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                },
+                "y": {
+                    "type": "string"
+                }
+            }
+        },
+        "tokens.JWKS": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tokens.JWK"
+                    }
+                }
+            }
+        },
+        "utils.ErrorBody": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/utils.ErrorCode"
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.FieldError"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                },
+                "requestId": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.ErrorCode": {
+            "type": "string",
+            "enum": [
+                "INVALID_INPUT",
+                "UNAUTHORIZED",
+                "FORBIDDEN",
+                "NOT_FOUND",
+                "METHOD_NOT_ALLOWED",
+                "PAYLOAD_TOO_LARGE",
+                "RATE_LIMITED",
+                "INTERNAL_ERROR",
+                "SERVICE_UNAVAILABLE",
+                "USERNAME_TAKEN",
+                "EMAIL_TAKEN",
+                "INVALID_CREDENTIALS",
+                "LOGIN_LOCKED",
+                "INVALID_TOKEN",
+                "WRONG_PASSWORD",
+                "PUBLIC_KEY_MISMATCH",
+                "IDENTITY_NOT_LINKED",
+                "LAST_SIGN_IN_METHOD",
+                "QUOTA_EXCEEDED",
+                "FILE_NOT_UPLOADED",
+                "RECIPIENT_NOT_FOUND",
+                "RECIPIENT_UNVERIFIED",
+                "TRANSFER_NOT_FOUND",
+                "TRANSFER_EXPIRED",
+                "NOT_A_RECIPIENT",
+                "RECIPIENT_KEYS_RESET",
+                "FILE_NOT_FOUND",
+                "INVALID_SIGNATURE",
+                "INSUFFICIENT_SCOPE",
+                "SESSION_REQUIRED",
+                "CSRF_TOKEN_INVALID"
+            ],
+            "x-enum-comments": {
+                "CodeCSRFTokenInvalid": "The X-CSRF-Token header is missing or doesn't match, fetch a new token",
+                "CodeEmailTaken": "Another account uses this email address",
+                "CodeFileNotFound": "The transfer has no such file",
+                "CodeFileNotUploaded": "A file of the transfer is missing from storage",
+                "CodeForbidden": "The session may not perform this action",
+                "CodeIdentityNotLinked": "The provider isn't linked to the account",
+                "CodeInsufficientScope": "The access token wasn't granted the scope the route requires",
+                "CodeInternal": "Unexpected server error, report it with the request ID",
+                "CodeInvalidCredentials": "Unknown username or wrong password",
+                "CodeInvalidInput": "The request is malformed or fails validation",
+                "CodeInvalidSignature": "The presigned URL is tampered with or expired",
+                "CodeInvalidToken": "The emailed token is unknown, used or expired",
+                "CodeLastSignInMethod": "The identity is the account's only way to sign in",
+                "CodeLoginLocked": "Too many failed logins, retry after data.retry_after seconds",
+                "CodeMethodNotAllowed": "The route doesn't support this HTTP method",
+                "CodeNotARecipient": "The transfer wasn't shared with this account",
+                "CodeNotFound": "The resource doesn't exist",
+                "CodePayloadTooLarge": "The request body exceeds the size limit",
+                "CodePublicKeyMismatch": "The public key isn't the account's, set resetKeys to replace it",
+                "CodeQuotaExceeded": "The transfer exceeds the upload size limit",
+                "CodeRateLimited": "Too many requests, retry after the RateLimit-Reset header",
+                "CodeRecipientKeysReset": "The transfer was encrypted for a key pair that was since reset",
+                "CodeRecipientNotFound": "No account has the recipient's public key",
+                "CodeRecipientUnverified": "The recipient hasn't verified their email address",
+                "CodeSessionRequired": "The route can't be used with an access token, sign in instead",
+                "CodeTransferExpired": "The transfer can no longer be downloaded",
+                "CodeTransferNotFound": "The share link is invalid or the transfer was deleted",
+                "CodeUnauthorized": "No valid session",
+                "CodeUnavailable": "A dependency is down, retry later",
+                "CodeUsernameTaken": "Another account uses this username",
+                "CodeWrongPassword": "The current password is incorrect; 400 since clients sign out on 401"
+            },
+            "x-enum-descriptions": [
+                "The request is malformed or fails validation",
+                "No valid session",
+                "The session may not perform this action",
+                "The resource doesn't exist",
+                "The route doesn't support this HTTP method",
+                "The request body exceeds the size limit",
+                "Too many requests, retry after the RateLimit-Reset header",
+                "Unexpected server error, report it with the request ID",
+                "A dependency is down, retry later",
+                "Another account uses this username",
+                "Another account uses this email address",
+                "Unknown username or wrong password",
+                "Too many failed logins, retry after data.retry_after seconds",
+                "The emailed token is unknown, used or expired",
+                "The current password is incorrect; 400 since clients sign out on 401",
+                "The public key isn't the account's, set resetKeys to replace it",
+                "The provider isn't linked to the account",
+                "The identity is the account's only way to sign in",
+                "The transfer exceeds the upload size limit",
+                "A file of the transfer is missing from storage",
+                "No account has the recipient's public key",
+                "The recipient hasn't verified their email address",
+                "The share link is invalid or the transfer was deleted",
+                "The transfer can no longer be downloaded",
+                "The transfer wasn't shared with this account",
+                "The transfer was encrypted for a key pair that was since reset",
+                "The transfer has no such file",
+                "The presigned URL is tampered with or expired",
+                "The access token wasn't granted the scope the route requires",
+                "The route can't be used with an access token, sign in instead",
+                "The X-CSRF-Token header is missing or doesn't match, fetch a new token"
+            ],
+            "x-enum-varnames": [
+                "CodeInvalidInput",
+                "CodeUnauthorized",
+                "CodeForbidden",
+                "CodeNotFound",
+                "CodeMethodNotAllowed",
+                "CodePayloadTooLarge",
+                "CodeRateLimited",
+                "CodeInternal",
+                "CodeUnavailable",
+                "CodeUsernameTaken",
+                "CodeEmailTaken",
+                "CodeInvalidCredentials",
+                "CodeLoginLocked",
+                "CodeInvalidToken",
+                "CodeWrongPassword",
+                "CodePublicKeyMismatch",
+                "CodeIdentityNotLinked",
+                "CodeLastSignInMethod",
+                "CodeQuotaExceeded",
+                "CodeFileNotUploaded",
+                "CodeRecipientNotFound",
+                "CodeRecipientUnverified",
+                "CodeTransferNotFound",
+                "CodeTransferExpired",
+                "CodeNotARecipient",
+                "CodeRecipientKeysReset",
+                "CodeFileNotFound",
+                "CodeInvalidSignature",
+                "CodeInsufficientScope",
+                "CodeSessionRequired",
+                "CodeCSRFTokenInvalid"
+            ]
+        },
+        "utils.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
         "utils.Payload": {
             "type": "object",
             "properties": {
                 "data": {},
+                "error": {
+                    "description": "set on failed responses",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/utils.ErrorBody"
+                        }
+                    ]
+                },
                 "message": {
                     "type": "string"
                 },
+                "requestId": {
+                    "description": "set on errors to correlate with server logs",
+                    "type": "string"
+                },
                 "success": {
                     "type": "boolean"
                 }
@@ -0,0 +1,493 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohits-web03/obscyra/internal/api/middleware"
+	"github.com/rohits-web03/obscyra/internal/mailer"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	verifyEmailTokenTTL   = 24 * time.Hour
+	resetPasswordTokenTTL = 1 * time.Hour
+)
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+type EmailInput struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+}
+
+type VerifyEmailInput struct {
+	Token string `json:"token" validate:"required,max=256"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" validate:"required,max=256"`
+	Password string `json:"password" validate:"required,password"`
+	// EncryptedPrivateKey is the private key wrapped with the new password.
+	// Without ResetKeys it must be the existing key pair re-wrapped client side.
+	EncryptedPrivateKey string `json:"encryptedPrivateKey" validate:"required,max=16384"`
+	// ResetKeys replaces the key pair with PublicKey/EncryptedPrivateKey and
+	// marks data encrypted for the old public key as unrecoverable.
+	ResetKeys bool   `json:"resetKeys"`
+	PublicKey string `json:"publicKey" validate:"publickey,max=8192"`
+}
+
+// hashUserToken derives the stored form of an emailed token. The hash is keyed
+// and bound to the purpose, so a token can't be reused for another flow.
+func (h *handler) hashUserToken(purpose, token string) string {
+	mac := hmac.New(sha256.New, []byte(h.Config.JWTSecret))
+	mac.Write([]byte(purpose + ":" + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issueUserToken creates a single-use token for the user, invalidating any
+// outstanding token with the same purpose.
+func (h *handler) issueUserToken(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := h.Now()
+	err = h.Repos.Transaction(ctx, func(tx repositories.Repositories) error {
+		if err := tx.UserTokens.Invalidate(ctx, userID, purpose, now); err != nil {
+			return err
+		}
+		return tx.UserTokens.Create(ctx, &models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: h.hashUserToken(purpose, token),
+			ExpiresAt: now.Add(ttl),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeUserToken atomically marks a token as used and returns it. Expired,
+// used or unknown tokens return errInvalidUserToken.
+func (h *handler) consumeUserToken(ctx context.Context, tx repositories.Repositories, purpose, token string) (*models.UserToken, error) {
+	if token == "" {
+		return nil, errInvalidUserToken
+	}
+
+	userToken, err := tx.UserTokens.Consume(ctx, purpose, h.hashUserToken(purpose, token), h.Now())
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, errInvalidUserToken
+	}
+	return userToken, err
+}
+
+// deliverMail sends an email in the background so response times don't
+// reveal whether an account exists.
+func (h *handler) deliverMail(ctx context.Context, msg mailer.Message) {
+	h.Mailer.SendAsync(ctx, msg)
+}
+
+// sendVerificationEmail issues a verify-email token and mails the link to the user.
+func (h *handler) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := h.issueUserToken(ctx, user.ID, models.TokenPurposeVerifyEmail, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := h.Config.FrontendURL + "/verify-email?" + url.Values{"token": {token}}.Encode()
+	h.deliverMail(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Obscyra email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in 24 hours. If you didn't create an Obscyra account you can ignore this email.\n",
+			user.Username, link),
+	})
+	return nil
+}
+
+// POST /api/v1/auth/verify-email
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Consumes a single-use verification token sent by email and marks the address as verified.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param input body VerifyEmailInput true "Verification token"
+// @Success 200 {object} utils.Payload "Email verified successfully"
+// @Failure 400 {object} utils.Payload "Invalid or expired token"
+// @Failure 413 {object} utils.Payload "Request body too large"
+// @Router /api/v1/auth/verify-email [post]
+func (h *AccountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var input VerifyEmailInput
+
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+
+	ctx := r.Context()
+	err := h.Repos.Transaction(ctx, func(tx repositories.Repositories) error {
+		userToken, err := h.consumeUserToken(ctx, tx, models.TokenPurposeVerifyEmail, input.Token)
+		if err != nil {
+			return err
+		}
+		user, err := tx.Users.GetForUpdate(ctx, userToken.UserID)
+		if err != nil {
+			return err
+		}
+		user.EmailVerified = true
+		return tx.Users.Update(ctx, user)
+	})
+
+	switch {
+	case errors.Is(err, errInvalidUserToken):
+		utils.ErrorResponse(w, utils.CodeInvalidToken, "Invalid or expired token")
+		return
+	case err != nil:
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "Email verified successfully",
+	})
+}
+
+// POST /api/v1/auth/verify-email/resend
+// ResendVerificationEmail godoc
+// @Summary Resend verification email
+// @Description Sends a new verification link if an unverified account exists for the address. The response is the same whether or not it does.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param input body EmailInput true "Account email"
+// @Success 200 {object} utils.Payload "Verification email sent if the account exists"
+// @Failure 400 {object} utils.Payload "Invalid input"
+// @Failure 413 {object} utils.Payload "Request body too large"
+// @Router /api/v1/auth/verify-email/resend [post]
+func (h *AccountHandler) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var input EmailInput
+
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+
+	user, err := h.Repos.Users.GetByEmail(r.Context(), input.Email)
+	if err == nil {
+		if user.EmailVerified {
+			// Same response as for unknown addresses
+		} else if err := h.sendVerificationEmail(r.Context(), user); err != nil {
+			h.Logger.ErrorContext(r.Context(), "Failed to issue verification token", "error", err)
+		}
+	} else if !errors.Is(err, repositories.ErrNotFound) {
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "If an unverified account exists for this email, a verification link has been sent",
+	})
+}
+
+// POST /api/v1/auth/password/forgot
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Emails a single-use password reset link if an account exists for the address. The response is the same whether or not it does.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param input body EmailInput true "Account email"
+// @Success 200 {object} utils.Payload "Reset email sent if the account exists"
+// @Failure 400 {object} utils.Payload "Invalid input"
+// @Failure 413 {object} utils.Payload "Request body too large"
+// @Router /api/v1/auth/password/forgot [post]
+func (h *AccountHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var input EmailInput
+
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+
+	user, err := h.Repos.Users.GetByEmail(r.Context(), input.Email)
+	switch {
+	case err == nil:
+		token, err := h.issueUserToken(r.Context(), user.ID, models.TokenPurposeResetPassword, resetPasswordTokenTTL)
+		if err != nil {
+			h.Logger.ErrorContext(r.Context(), "Failed to issue password reset token", "error", err)
+			break
+		}
+
+		link := h.Config.FrontendURL + "/reset-password?" + url.Values{"token": {token}}.Encode()
+		h.deliverMail(r.Context(), mailer.Message{
+			To:      user.Email,
+			Subject: "Reset your Obscyra password",
+			Body: fmt.Sprintf("Hi %s,\n\nReset your password by opening the link below:\n\n%s\n\n"+
+				"The link expires in 1 hour. If you didn't request a password reset you can ignore this email.\n",
+				user.Username, link),
+		})
+	case !errors.Is(err, repositories.ErrNotFound):
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "If an account exists for this email, a password reset link has been sent",
+	})
+}
+
+// POST /api/v1/auth/password/reset
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Sets a new password using a reset token and signs out all sessions. Because the private key is wrapped with the password, the request must either carry the existing private key re-wrapped with the new password, or set resetKeys with a new key pair, which marks data encrypted for the old public key as unrecoverable.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param input body ResetPasswordInput true "Reset payload"
+// @Success 200 {object} utils.Payload "Password reset successfully"
+// @Failure 400 {object} utils.Payload "Invalid input or invalid/expired token"
+// @Failure 413 {object} utils.Payload "Request body too large"
+// @Router /api/v1/auth/password/reset [post]
+func (h *AccountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var input ResetPasswordInput
+
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+
+	if input.ResetKeys && input.PublicKey == "" {
+		utils.ErrorResponse(w, utils.CodeInvalidInput, "A new public key is required when resetting keys",
+			utils.FieldError{Field: "publicKey", Message: "is required when resetKeys is set"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Failed to hash password")
+		return
+	}
+
+	errKeyMismatch := errors.New("public key does not match the account")
+
+	ctx := r.Context()
+	err = h.Repos.Transaction(ctx, func(tx repositories.Repositories) error {
+		userToken, err := h.consumeUserToken(ctx, tx, models.TokenPurposeResetPassword, input.Token)
+		if err != nil {
+			return err
+		}
+
+		user, err := tx.Users.GetForUpdate(ctx, userToken.UserID)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case input.ResetKeys:
+			if user.PublicKey != "" && user.PublicKey != input.PublicKey {
+				now := h.Now()
+				user.KeysResetAt = &now
+			}
+			user.PublicKey = input.PublicKey
+		case user.PublicKey == "":
+			// Accounts without keys (e.g. created through an identity
+			// provider) get their first key pair
+			user.PublicKey = input.PublicKey
+		case input.PublicKey != "" && input.PublicKey != user.PublicKey:
+			return errKeyMismatch
+		}
+
+		user.Password = string(hashedPassword)
+		user.EncryptedPrivateKey = input.EncryptedPrivateKey
+		// Following the emailed link proves ownership of the address
+		user.EmailVerified = true
+		// Sign out every existing session and revoke the access tokens,
+		// which may have been created by whoever knew the old password
+		user.SessionVersion++
+		if err := tx.AccessTokens.DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		return tx.Users.Update(ctx, user)
+	})
+
+	switch {
+	case errors.Is(err, errInvalidUserToken):
+		utils.ErrorResponse(w, utils.CodeInvalidToken, "Invalid or expired token")
+		return
+	case errors.Is(err, errKeyMismatch):
+		utils.ErrorResponse(w, utils.CodePublicKeyMismatch, "Public key does not match the account; set resetKeys to replace the key pair")
+		return
+	case err != nil:
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "Password reset successfully",
+	})
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"currentPassword" validate:"required,max=1024"`
+	NewPassword     string `json:"newPassword" validate:"required,password"`
+	// EncryptedPrivateKey is the existing private key re-wrapped client side
+	// with a key derived from the new password.
+	EncryptedPrivateKey string `json:"encryptedPrivateKey" validate:"required,max=16384"`
+}
+
+// POST /api/v1/me/password
+// ChangePassword godoc
+// @Summary Change password
+// @Description Verifies the current password, then stores the new password hash and the re-wrapped private key in one transaction. All other sessions are signed out; the current session receives a fresh cookie.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Param input body ChangePasswordInput true "Password change payload"
+// @Success 200 {object} utils.Payload "Password changed successfully"
+// @Failure 400 {object} utils.Payload "Invalid input or current password is incorrect"
+// @Failure 413 {object} utils.Payload "Request body too large"
+// @Failure 429 {object} utils.Payload "Too many wrong passwords, see Retry-After"
+// @Router /api/v1/me/password [post]
+func (h *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := currentUserID(r)
+	if !ok {
+		utils.ErrorResponse(w, utils.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var input ChangePasswordInput
+
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+
+	ctx := r.Context()
+	user, err := h.Repos.Users.GetByID(ctx, userID)
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+
+	// Wrong guesses count against the same limits as failed logins, so a
+	// stolen session can't brute-force the password
+	userKey := "user:" + strings.ToLower(user.Username)
+	throttleKeys := []string{userKey, "ip:" + middleware.ClientIP(r)}
+	wait, err := h.Logins.Check(ctx, throttleKeys...)
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+	if wait > 0 {
+		loginLockedOut(w, wait)
+		return
+	}
+
+	// Accounts created through an identity provider have no password to
+	// verify. The comparison runs before the row is locked, so the lock
+	// isn't held for the bcrypt cost.
+	if user.Password == "" || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)) != nil {
+		wait, err := h.Logins.Fail(ctx, throttleKeys...)
+		if err != nil {
+			h.Logger.ErrorContext(ctx, "Failed to record failed password change", "error", err)
+		}
+		if wait > 0 {
+			loginLockedOut(w, wait)
+			return
+		}
+		utils.ErrorResponse(w, utils.CodeWrongPassword, "Current password is incorrect")
+		return
+	}
+	if err := h.Logins.Succeed(ctx, userKey); err != nil {
+		h.Logger.ErrorContext(ctx, "Failed to reset login throttle", "error", err)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Failed to hash password")
+		return
+	}
+
+	errWrongPassword := errors.New("current password is incorrect")
+	verifiedHash := user.Password
+
+	err = h.Repos.Transaction(ctx, func(tx repositories.Repositories) error {
+		// Lock the row so concurrent changes can't interleave the hash and key updates
+		user, err = tx.Users.GetForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		// The password changed since it was verified
+		if user.Password != verifiedHash {
+			return errWrongPassword
+		}
+
+		user.Password = string(hashedPassword)
+		user.EncryptedPrivateKey = input.EncryptedPrivateKey
+		user.SessionVersion++
+		if err := tx.AccessTokens.DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		return tx.Users.Update(ctx, user)
+	})
+
+	switch {
+	case errors.Is(err, errWrongPassword):
+		utils.ErrorResponse(w, utils.CodeWrongPassword, "Current password is incorrect")
+		return
+	case err != nil:
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+
+	// Keep the current session alive with a token for the new session version
+	if err := h.setSessionCookie(w, user); err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Failed to create token")
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "Password changed successfully",
+	})
+}
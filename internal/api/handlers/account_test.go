@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rohits-web03/obscyra/internal/api/middleware"
+	"github.com/rohits-web03/obscyra/internal/mailer"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/throttle"
+	"github.com/rohits-web03/obscyra/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// captureSender records the messages sent through it
+type captureSender struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (s *captureSender) Send(ctx context.Context, msg mailer.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func (s *captureSender) messages() []mailer.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]mailer.Message(nil), s.sent...)
+}
+
+func TestForgotPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantCode utils.ErrorCode // empty for success
+		wantMail bool
+	}{
+		{name: "known email", body: `{"email":"alice@example.com"}`, wantMail: true},
+		// Same response as for a known email, so it doesn't reveal accounts
+		{name: "unknown email", body: `{"email":"bob@example.com"}`},
+		{name: "invalid email", body: `{"email":"alice"}`, wantCode: utils.CodeInvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(t)
+			sender := &captureSender{}
+			a.Mailer = mailer.NewMailer(sender)
+			if err := a.Repos.Users.Create(context.Background(), &models.User{Username: "alice", Email: "alice@example.com"}); err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			NewAccountHandler(a).ForgotPassword(w, httptest.NewRequest(http.MethodPost, "/api/v1/auth/password/forgot", strings.NewReader(tt.body)))
+			if err := a.Mailer.Wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			payload := decodePayload(t, w)
+
+			if tt.wantCode != "" {
+				if payload.Error == nil || payload.Error.Code != tt.wantCode {
+					t.Fatalf("response %s, want error %s", w.Body, tt.wantCode)
+				}
+			} else if w.Code != http.StatusOK || !payload.Success {
+				t.Fatalf("response %d %s, want success", w.Code, w.Body)
+			}
+
+			sent := sender.messages()
+			if !tt.wantMail {
+				if len(sent) != 0 {
+					t.Errorf("sent %d emails, want none", len(sent))
+				}
+				return
+			}
+			if len(sent) != 1 || sent[0].To != "alice@example.com" || !strings.Contains(sent[0].Body, a.Config.FrontendURL+"/reset-password?token=") {
+				t.Errorf("sent %+v, want one reset link to alice", sent)
+			}
+		})
+	}
+}
+
+// TestResetPasswordWithEmailedToken follows the link of a reset email and
+// checks the token can only be used once.
+func TestResetPasswordWithEmailedToken(t *testing.T) {
+	ctx := context.Background()
+	a := newTestApp(t)
+	sender := &captureSender{}
+	a.Mailer = mailer.NewMailer(sender)
+	user := &models.User{Username: "alice", Email: "alice@example.com"}
+	if err := a.Repos.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	// An access token created by whoever knew the old password
+	if err := a.Repos.AccessTokens.Create(ctx, &models.AccessToken{UserID: user.ID, Name: "cli", Hint: "cli", TokenHash: "hash", Scopes: models.ScopeInboxRead}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewAccountHandler(a)
+
+	// Only the token of the latest email is valid
+	var tokens []string
+	for range 2 {
+		w := httptest.NewRecorder()
+		h.ForgotPassword(w, httptest.NewRequest(http.MethodPost, "/api/v1/auth/password/forgot", strings.NewReader(`{"email":"alice@example.com"}`)))
+		if err := a.Mailer.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		sent := sender.messages()
+		link := regexp.MustCompile(`\S+/reset-password\?\S+`).FindString(sent[len(sent)-1].Body)
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, u.Query().Get("token"))
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode utils.ErrorCode // empty for success
+	}{
+		{name: "superseded token", token: tokens[0], wantCode: utils.CodeInvalidToken},
+		{name: "latest token", token: tokens[1]},
+		{name: "used token", token: tokens[1], wantCode: utils.CodeInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"token":"` + tt.token + `","password":"NewPassword1","encryptedPrivateKey":"wrapped","publicKey":"MCowBQYDK2VwAyEA"}`
+			w := httptest.NewRecorder()
+			h.ResetPassword(w, httptest.NewRequest(http.MethodPost, "/api/v1/auth/password/reset", strings.NewReader(body)))
+			payload := decodePayload(t, w)
+
+			if tt.wantCode != "" {
+				if payload.Error == nil || payload.Error.Code != tt.wantCode {
+					t.Fatalf("response %s, want error %s", w.Body, tt.wantCode)
+				}
+				return
+			}
+			if !payload.Success {
+				t.Fatalf("response %s, want success", w.Body)
+			}
+
+			updated, err := a.Repos.Users.GetByID(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bcrypt.CompareHashAndPassword([]byte(updated.Password), []byte("NewPassword1")) != nil {
+				t.Error("password not changed")
+			}
+			if !updated.EmailVerified || updated.SessionVersion != user.SessionVersion+1 || updated.PublicKey != "MCowBQYDK2VwAyEA" {
+				t.Errorf("user after reset = %+v", updated)
+			}
+			if tokens, err := a.Repos.AccessTokens.ListByUser(ctx, user.ID); err != nil || len(tokens) != 0 {
+				t.Errorf("access tokens after reset = %v, %v; want none", tokens, err)
+			}
+		})
+	}
+}
+
+// TestChangePassword sends a sequence of password changes for one signed in
+// user. Wrong current passwords count towards the login lockout.
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	a := newTestApp(t)
+	a.Config.LoginThrottle.FreeAttempts = 2
+	logins, err := throttle.NewLogins(a.Config.LoginThrottle, nil, a.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Logins = logins
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("OldPassword1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{Username: "Alice", Email: "alice@example.com", Password: string(hash)}
+	if err := a.Repos.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Repos.AccessTokens.Create(ctx, &models.AccessToken{UserID: user.ID, Name: "cli", Hint: "cli", TokenHash: "hash", Scopes: models.ScopeInboxRead}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewAccountHandler(a)
+
+	change := func(current string) (*httptest.ResponseRecorder, utils.Payload) {
+		body := `{"currentPassword":"` + current + `","newPassword":"NewPassword1","encryptedPrivateKey":"rewrapped"}`
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/me/password", strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, user.ID.String()))
+		w := httptest.NewRecorder()
+		h.ChangePassword(w, r)
+		return w, decodePayload(t, w)
+	}
+
+	tests := []struct {
+		name       string
+		current    string
+		wantStatus int
+		wantCode   utils.ErrorCode // empty for success
+	}{
+		{name: "wrong password", current: "guess1", wantStatus: http.StatusBadRequest, wantCode: utils.CodeWrongPassword},
+		{name: "second wrong password", current: "guess2", wantStatus: http.StatusBadRequest, wantCode: utils.CodeWrongPassword},
+		{name: "locked out", current: "guess3", wantStatus: http.StatusTooManyRequests, wantCode: utils.CodeLoginLocked},
+		// The lockout applies to the right password too
+		{name: "right password while locked out", current: "OldPassword1", wantStatus: http.StatusTooManyRequests, wantCode: utils.CodeLoginLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, payload := change(tt.current)
+			if w.Code != tt.wantStatus || payload.Error == nil || payload.Error.Code != tt.wantCode {
+				t.Fatalf("response %d %s, want %d %s", w.Code, w.Body, tt.wantStatus, tt.wantCode)
+			}
+			if tt.wantCode == utils.CodeLoginLocked && w.Header().Get("Retry-After") == "" {
+				t.Error("lockout without Retry-After")
+			}
+		})
+	}
+
+	// Once the lockout is over, the right password changes it
+	if err := a.Logins.Succeed(ctx, "user:alice", "ip:"+middleware.ClientIP(httptest.NewRequest(http.MethodPost, "/", nil))); err != nil {
+		t.Fatal(err)
+	}
+	w, payload := change("OldPassword1")
+	if !payload.Success {
+		t.Fatalf("response %d %s, want success", w.Code, w.Body)
+	}
+	updated, err := a.Repos.Users.GetByID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(updated.Password), []byte("NewPassword1")) != nil || updated.EncryptedPrivateKey != "rewrapped" {
+		t.Error("password not changed")
+	}
+	if updated.SessionVersion != user.SessionVersion+1 {
+		t.Errorf("session version = %d, want %d", updated.SessionVersion, user.SessionVersion+1)
+	}
+	if tokens, err := a.Repos.AccessTokens.ListByUser(ctx, user.ID); err != nil || len(tokens) != 0 {
+		t.Errorf("access tokens after change = %v, %v; want none", tokens, err)
+	}
+}
@@ -1,89 +1,65 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/rohits-web03/obscyra/internal/api/services"
-	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/google/uuid"
+	"github.com/rohits-web03/obscyra/internal/api/middleware"
+	"github.com/rohits-web03/obscyra/internal/audit"
 	"github.com/rohits-web03/obscyra/internal/models"
 	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/tokens"
 	"github.com/rohits-web03/obscyra/internal/utils"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 // POST /auth/sign-up
-func RegisterUser(w http.ResponseWriter, r *http.Request) {
+func (h *AuthHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		utils.JSONResponse(w, http.StatusMethodNotAllowed, utils.Payload{
-			Success: false,
-			Message: "Method not allowed",
-		})
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	type Input struct {
-		Username            string `json:"username"`
-		Email               string `json:"email"`
-		Password            string `json:"password"`
-		PublicKey           string `json:"publicKey"`
-		EncryptedPrivateKey string `json:"encryptedPrivateKey"`
+		// Limits are validation.MinUsernameLength and MaxUsernameLength
+		Username            string `json:"username" validate:"required,min=3,max=32,username"`
+		Email               string `json:"email" validate:"required,email,max=254"`
+		Password            string `json:"password" validate:"required,password"`
+		PublicKey           string `json:"publicKey" validate:"publickey,max=8192"`
+		EncryptedPrivateKey string `json:"encryptedPrivateKey" validate:"max=16384"`
 	}
 
 	var input Input
 
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(&input); err != nil {
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: "Invalid input",
-		})
-		return
-	}
-
-	if input.Email == "" || input.Username == "" || input.Password == "" {
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: "Invalid input",
-		})
+	if !decodeJSON(w, r, &input) {
 		return
 	}
 
 	// Check if username already exists
-	var existingUser models.User
-	if err := repositories.DB.Where("username = ?", input.Username).First(&existingUser).Error; err == nil {
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: "Username is already taken",
-		})
+	if _, err := h.Repos.Users.GetByUsername(r.Context(), input.Username); err == nil {
+		utils.ErrorResponse(w, utils.CodeUsernameTaken, "Username is already taken")
 		return
 	}
 
 	// Check if email already exists
-	err := repositories.DB.Where("email = ?", input.Email).First(&existingUser).Error
+	_, err := h.Repos.Users.GetByEmail(r.Context(), input.Email)
 
 	switch err {
 	case nil: // email exists
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: "User already exists with this email",
-		})
+		utils.ErrorResponse(w, utils.CodeEmailTaken, "User already exists with this email")
 		return
 
-	case gorm.ErrRecordNotFound: // new user, create account
+	case repositories.ErrNotFound: // new user, create account
 		hashedPassword, hashErr := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 		if hashErr != nil {
-			utils.JSONResponse(w, http.StatusInternalServerError, utils.Payload{
-				Success: false,
-				Message: "Failed to hash password",
-			})
+			utils.ErrorResponse(w, utils.CodeInternal, "Failed to hash password")
 			return
 		}
 
@@ -95,176 +71,217 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 			EncryptedPrivateKey: input.EncryptedPrivateKey,
 		}
 
-		if createErr := repositories.DB.Create(&newUser).Error; createErr != nil {
-			utils.JSONResponse(w, http.StatusInternalServerError, utils.Payload{
-				Success: false,
-				Message: "Database insert failed",
-			})
+		if createErr := h.Repos.Users.Create(r.Context(), &newUser); createErr != nil {
+			utils.ErrorResponse(w, utils.CodeInternal, "Database insert failed")
 			return
 		}
 
+		if mailErr := h.sendVerificationEmail(r.Context(), &newUser); mailErr != nil {
+			h.Logger.ErrorContext(r.Context(), "Failed to issue verification token", "error", mailErr)
+		}
+
 	default: // some other DB error
-		utils.JSONResponse(w, http.StatusInternalServerError, utils.Payload{
-			Success: false,
-			Message: "Database query failed",
-		})
+		utils.ErrorResponse(w, utils.CodeInternal, "Database query failed")
 		return
 	}
 
 	utils.JSONResponse(w, http.StatusCreated, utils.Payload{
 		Success: true,
-		Message: "User registered successfully",
+		Message: "User registered successfully, check your email to verify your address",
 	})
 }
 
-// JWT Claims struct
-type Claims struct {
-	UserID   string `json:"userId"`
-	Username string `json:"username"`
-	jwt.RegisteredClaims
+// currentUserID returns the authenticated user's ID set by AuthMiddleware.
+func currentUserID(r *http.Request) (uuid.UUID, bool) {
+	idStr, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || idStr == "" {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// issueSessionToken signs a session JWT for the given user.
+func (h *handler) issueSessionToken(user *models.User) (string, time.Time, error) {
+	now := h.Now()
+	tokenString, err := h.Tokens.Sign(tokens.SessionClaims{
+		UserID:         user.ID.String(),
+		Username:       user.Username,
+		SessionVersion: user.SessionVersion,
+	}, now, h.Config.SessionTTL)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, now.Add(h.Config.SessionTTL), nil
+}
+
+// setSessionCookie issues a session JWT for the user and stores it in the
+// token cookie.
+func (h *handler) setSessionCookie(w http.ResponseWriter, user *models.User) error {
+	tokenString, expiration, err := h.issueSessionToken(user)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, h.authCookie("token", tokenString, int(expiration.Sub(h.Now()).Seconds())))
+	return nil
+}
+
+// authCookie builds a cookie sent with API requests from the frontend. In
+// production the frontend is on another site, so it needs SameSite=None.
+func (h *handler) authCookie(name, value string, maxAge int) *http.Cookie {
+	// Check if we’re in production
+	isProd := h.Config.IsProduction()
+
+	// SameSite cookie policy
+	sameSite := http.SameSiteLaxMode
+	if isProd {
+		sameSite = http.SameSiteNoneMode
+	}
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   isProd,
+		HttpOnly: true,
+		SameSite: sameSite,
+	}
 }
 
 // POST /auth/login
-func LoginUser(w http.ResponseWriter, r *http.Request) {
+func (h *AuthHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		utils.JSONResponse(w, http.StatusMethodNotAllowed, utils.Payload{
-			Success: false,
-			Message: "Method not allowed",
-		})
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	// Parse request body
 	var input struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username string `json:"username" validate:"required,max=254"`
+		Password string `json:"password" validate:"required,max=1024"`
 	}
 
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(&input); err != nil {
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: "Invalid input",
-		})
+	if !decodeJSON(w, r, &input) {
 		return
 	}
 
-	if input.Username == "" || input.Password == "" {
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: "Invalid input",
-		})
+	// Failures are tracked per username and per client IP
+	ip := middleware.ClientIP(r)
+	userKey := "user:" + strings.ToLower(input.Username)
+	throttleKeys := []string{userKey, "ip:" + ip}
+	entry := models.AuditLog{
+		Username:  input.Username,
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+	}
+
+	wait, err := h.Logins.Check(r.Context(), throttleKeys...)
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+	if wait > 0 {
+		entry.Event = audit.EventLoginBlocked
+		audit.Record(r.Context(), h.Repos.AuditLogs, entry)
+		loginLockedOut(w, wait)
 		return
 	}
 
-	var user models.User
-	err := repositories.DB.Where("username = ?", input.Username).First(&user).Error
+	user, err := h.Repos.Users.GetByUsername(r.Context(), input.Username)
 	switch err {
 	case nil:
 		// user found
-	case gorm.ErrRecordNotFound:
-		utils.JSONResponse(w, http.StatusUnauthorized, utils.Payload{
-			Success: false,
-			Message: "Invalid credentials",
-		})
+		entry.UserID = &user.ID
+	case repositories.ErrNotFound:
+		h.loginFailed(w, r, entry, throttleKeys)
 		return
 	default:
-		utils.JSONResponse(w, http.StatusInternalServerError, utils.Payload{
-			Success: false,
-			Message: "Database error",
-		})
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
 		return
 	}
 
 	// Compare password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		utils.JSONResponse(w, http.StatusUnauthorized, utils.Payload{
-			Success: false,
-			Message: "Invalid credentials",
-		})
+		h.loginFailed(w, r, entry, throttleKeys)
 		return
 	}
 
-	// Load JWT secret
-	secret := config.Envs.JWTSecret
-	if secret == "" {
-		utils.JSONResponse(w, http.StatusInternalServerError, utils.Payload{
-			Success: false,
-			Message: "No config found for JWT",
-		})
+	// Only the username is cleared, so one valid account can't be used to
+	// reset the counter of an IP guessing other accounts
+	if err := h.Logins.Succeed(r.Context(), userKey); err != nil {
+		h.Logger.ErrorContext(r.Context(), "Failed to reset login throttle", "error", err)
+	}
+	entry.Event = audit.EventLoginSucceeded
+	audit.Record(r.Context(), h.Repos.AuditLogs, entry)
+
+	if err := h.setSessionCookie(w, user); err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Failed to create token")
 		return
 	}
 
-	// Build JWT claims
-	expiration := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		UserID:   user.ID.String(),
-		Username: user.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiration),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "Login successful",
+		Data: map[string]any{
+			"private_key": user.EncryptedPrivateKey,
+			"public_key":  user.PublicKey,
 		},
-	}
+	})
+}
 
-	// Sign token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secret))
+// loginFailed records a failed login and responds with either invalid
+// credentials or, once the failures trigger one, a lockout notice.
+func (h *handler) loginFailed(w http.ResponseWriter, r *http.Request, entry models.AuditLog, throttleKeys []string) {
+	wait, err := h.Logins.Fail(r.Context(), throttleKeys...)
 	if err != nil {
-		utils.JSONResponse(w, http.StatusInternalServerError, utils.Payload{
-			Success: false,
-			Message: "Failed to create token",
-		})
-		return
+		h.Logger.ErrorContext(r.Context(), "Failed to record failed login", "error", err)
 	}
 
-	// Cookie max-age
-	maxAge := int(expiration.Unix() - time.Now().Unix())
-
-	// Check if we’re in production
-	isProd := config.Envs.Environment == "production"
+	entry.Event = audit.EventLoginFailed
+	audit.Record(r.Context(), h.Repos.AuditLogs, entry)
 
-	// SameSite cookie policy
-	sameSite := http.SameSiteLaxMode
-	if isProd {
-		sameSite = http.SameSiteNoneMode
+	if wait > 0 {
+		entry.Event = audit.EventLoginLocked
+		entry.Detail = fmt.Sprintf("locked out for %s", wait.Round(time.Second))
+		audit.Record(r.Context(), h.Repos.AuditLogs, entry)
+		loginLockedOut(w, wait)
+		return
 	}
 
-	// Set cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    tokenString,
-		Path:     "/",
-		MaxAge:   maxAge,
-		Secure:   isProd,
-		HttpOnly: true,
-		SameSite: sameSite,
-	})
+	utils.ErrorResponse(w, utils.CodeInvalidCredentials, "Invalid credentials")
+}
 
-	utils.JSONResponse(w, http.StatusOK, utils.Payload{
-		Success: true,
-		Message: "Login successful",
+// loginLockedOut tells the client how long it has to wait before trying again.
+func loginLockedOut(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	message := fmt.Sprintf("Too many failed login attempts. Try again in %d seconds", seconds)
+	utils.JSONResponse(w, utils.CodeLoginLocked.Status(), utils.Payload{
+		Success: false,
+		Message: message,
+		Error:   &utils.ErrorBody{Code: utils.CodeLoginLocked, Message: message},
 		Data: map[string]any{
-			"private_key": user.EncryptedPrivateKey,
-			"public_key":  user.PublicKey,
+			"retry_after": seconds,
 		},
 	})
 }
 
 // POST /api/auth/logout
-func Logout(w http.ResponseWriter, r *http.Request) {
-	isProd := config.Envs.Environment == "production"
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	// Only unsafe methods are CSRF protected
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
 
-	// Delete the token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    "", // empty value
-		Path:     "/",
-		MaxAge:   -1, // maxAge < 0 deletes the cookie
-		Secure:   isProd,
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-	})
+	// Delete the token and CSRF cookies; maxAge < 0 deletes a cookie
+	http.SetCookie(w, h.authCookie("token", "", -1))
+	http.SetCookie(w, h.authCookie(middleware.CSRFCookie, "", -1))
 
 	utils.JSONResponse(w, http.StatusOK, utils.Payload{
 		Success: true,
@@ -272,44 +289,74 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func HandleGoogleLogin(w http.ResponseWriter, r *http.Request) {
-	redirectType := r.URL.Query().Get("redirect") // "login" or "register"
-	if redirectType == "" {
-		redirectType = "login" // default
+// GET /api/v1/csrf-token
+// CSRFToken godoc
+// @Summary Get a CSRF token
+// @Description Issues a CSRF token for the signed in user and sets it in the csrf_token cookie. Browser clients send it in the X-CSRF-Token header of every POST, PUT, PATCH and DELETE authenticated with the session cookie. Fetch a new token after signing in.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} utils.Payload "CSRF token issued"
+// @Failure 401 {object} utils.Payload "Unauthorized"
+// @Router /api/v1/csrf-token [get]
+func (h *AuthHandler) CSRFToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := currentUserID(r)
+	if !ok {
+		utils.ErrorResponse(w, utils.CodeUnauthorized, "Unauthorized")
+		return
 	}
 
-	state, err := GenerateState(map[string]string{"flow": redirectType})
+	token, err := middleware.NewCSRFToken(h.Config.JWTSecret, userID.String())
 	if err != nil {
-		http.Error(w, "Failed to generate OAuth state", http.StatusInternalServerError)
+		utils.ErrorResponse(w, utils.CodeInternal, "Failed to create token")
 		return
 	}
 
-	url := services.GoogleOauthConfig.AuthCodeURL(state)
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+	http.SetCookie(w, h.authCookie(middleware.CSRFCookie, token, int(h.Config.SessionTTL.Seconds())))
+	w.Header().Set("Cache-Control", "no-store")
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "CSRF token issued",
+		Data: map[string]any{
+			"csrf_token": token,
+		},
+	})
 }
 
-func HandleGoogleCallback(w http.ResponseWriter, r *http.Request) {
-	state := r.FormValue("state")
-	stateData, err := DecodeState(state)
-	if err != nil {
-		http.Error(w, "Invalid OAuth state", http.StatusBadRequest)
+func (h *AuthHandler) HandleGoogleLogin(w http.ResponseWriter, r *http.Request) {
+	h.startOAuthFlow(w, r, googleProvider, map[string]string{"flow": loginFlow(r)}) // "login" or "register"
+}
+
+func (h *AuthHandler) HandleGoogleCallback(w http.ResponseWriter, r *http.Request) {
+	stateData, ok := h.verifyCallbackState(w, r, googleProvider)
+	if !ok {
+		h.failOAuthFlow(w, r, nil, errInvalidState)
+		return
+	}
+
+	if code, failed := h.providerError(r); failed {
+		h.failOAuthFlow(w, r, stateData, code)
 		return
 	}
 
-	flowType := stateData["flow"] // "login" or "register"
 	code := r.FormValue("code")
 
-	token, err := services.GoogleOauthConfig.Exchange(context.Background(), code)
+	token, err := h.Providers.Google.Exchange(r.Context(), code)
 	if err != nil {
-		http.Error(w, "Code exchange failed", http.StatusInternalServerError)
-		fmt.Println("Exchange error:", err)
+		h.Logger.ErrorContext(r.Context(), "Google code exchange failed", "error", err)
+		h.failOAuthFlow(w, r, stateData, errProviderError)
 		return
 	}
 
-	client := services.GoogleOauthConfig.Client(context.Background(), token)
+	client := h.Providers.Google.Client(r.Context(), token)
 	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
 	if err != nil {
-		http.Error(w, "Failed to get user info", http.StatusInternalServerError)
+		h.Logger.ErrorContext(r.Context(), "Failed to get Google user info", "error", err)
+		h.failOAuthFlow(w, r, stateData, errProviderUnavailable)
 		return
 	}
 	defer resp.Body.Close()
@@ -317,85 +364,24 @@ func HandleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 	data, _ := io.ReadAll(resp.Body)
 
 	var googleUser struct {
-		ID      string `json:"id"`
-		Email   string `json:"email"`
-		Name    string `json:"name"`
-		Picture string `json:"picture"`
-	}
-
-	if err := json.Unmarshal(data, &googleUser); err != nil {
-		http.Error(w, "Failed to parse user info", http.StatusInternalServerError)
-		return
-	}
-
-	// Check if user exists
-	var existingUser models.User
-	err = repositories.DB.Where("email = ?", googleUser.Email).First(&existingUser).Error
-
-	switch flowType {
-	case "register":
-		// If registering but user already exists
-		if err == nil {
-			http.Redirect(w, r, "http://localhost:5173/login?error=user_already_exists", http.StatusTemporaryRedirect)
-			return
-		}
-		// Create new user
-		newUser := models.User{
-			Username:  googleUser.Name,
-			Email:     googleUser.Email,
-			Password:  "", // Google-authenticated
-			CreatedAt: time.Now(),
-		}
-		if err := repositories.DB.Create(&newUser).Error; err != nil {
-			http.Error(w, "Failed to create user", http.StatusInternalServerError)
-			return
-		}
-		existingUser = newUser
-
-	case "login":
-		// If logging in but user not found
-		if err == gorm.ErrRecordNotFound {
-			http.Redirect(w, r, "http://localhost:5173/register?error=user_not_found", http.StatusTemporaryRedirect)
-			return
-		} else if err != nil {
-			http.Error(w, "Database error", http.StatusInternalServerError)
-			return
-		}
+		ID            string `json:"id"`
+		Email         string `json:"email"`
+		VerifiedEmail bool   `json:"verified_email"`
+		Name          string `json:"name"`
+		Picture       string `json:"picture"`
 	}
 
-	// Issue JWT
-	secret := config.Envs.JWTSecret
-	expiration := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		UserID:   existingUser.ID.String(),
-		Username: existingUser.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiration),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
-	if err != nil {
-		http.Error(w, "Failed to create JWT", http.StatusInternalServerError)
+	if err := json.Unmarshal(data, &googleUser); err != nil || googleUser.ID == "" {
+		h.Logger.ErrorContext(r.Context(), "Failed to parse Google user info", "status", resp.StatusCode)
+		h.failOAuthFlow(w, r, stateData, errProviderError)
 		return
 	}
 
-	// Set cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    tokenString,
-		Path:     "/",
-		MaxAge:   int((24 * time.Hour).Seconds()),
-		HttpOnly: true,
-		Secure:   config.Envs.Environment == "production",
-		SameSite: http.SameSiteLaxMode,
+	h.completeOAuthFlow(w, r, stateData, oauthProfile{
+		Provider:      googleProvider,
+		Subject:       googleUser.ID,
+		Email:         googleUser.Email,
+		EmailVerified: googleUser.VerifiedEmail,
+		Name:          googleUser.Name,
 	})
-
-	// Redirect user
-	redirectURL := "http://localhost:5173/share/send?status=success_login"
-	if flowType == "register" {
-		redirectURL = "http://localhost:5173/share/send?status=success_register"
-	}
-
-	http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 }
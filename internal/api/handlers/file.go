@@ -1,25 +1,22 @@
 package handlers
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
-	"golang.org/x/sync/errgroup"
-
 	"github.com/google/uuid"
-	"github.com/rohits-web03/obscyra/internal/api/middleware"
-	"github.com/rohits-web03/obscyra/internal/models"
-	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/api/services"
 	"github.com/rohits-web03/obscyra/internal/utils"
-	"gorm.io/gorm"
 )
 
+// PresignInput lists the files of a transfer, 1 to services.MaxTransferFiles
+// of them.
 type PresignInput []struct {
-	Filename string `json:"filename"`
-	Size     int64  `json:"size"`
+	Filename string `json:"filename" validate:"required,max=255,printable"`
+	Size     int64  `json:"size" validate:"min=0"`
 }
 
 type PresignedFile struct {
@@ -34,95 +31,64 @@ type PresignResponse struct {
 }
 
 type RecipientInput struct {
-	PublicKey    string `json:"publicKey"`    // Used to find the User ID
-	EncryptedKey string `json:"encryptedKey"` // The encrypted AES key
+	PublicKey    string `json:"publicKey" validate:"required,publickey,max=8192"` // Used to find the User ID
+	EncryptedKey string `json:"encryptedKey" validate:"required,max=16384"`       // The encrypted AES key
 }
 
 type CompleteUploadInput struct {
-	Token string `json:"token"`
+	Token string `json:"token" validate:"required,max=256"`
 	Files []struct {
-		Filename    string `json:"filename"`
-		Size        int64  `json:"size"`
-		Key         string `json:"key"`
-		ContentType string `json:"contentType"`
-	} `json:"files"`
+		Filename    string `json:"filename" validate:"required,max=255,printable"`
+		Size        int64  `json:"size" validate:"min=0"`
+		Key         string `json:"key" validate:"required,max=1024"`
+		ContentType string `json:"contentType" validate:"max=255,printable"`
+	} `json:"files" validate:"required,max=100"` // services.MaxTransferFiles
 	RecipientKeys []RecipientInput `json:"recipientKeys"`
 }
 
-const maxUploadSize = 100 << 20 // 100 MB
-
 // POST /api/v1/files/presign
 // PresignUpload generates presigned URLs for uploading files to R2 storage.
 // @Summary Generate presigned URLs for file upload
-// @Description Accepts a list of files (name and size), validates the total size, and returns presigned PUT URLs for each file. Each upload session is identified by a unique token.
+// @Description Accepts a list of 1 to 100 files (name and size), validates the total size, and returns presigned PUT URLs for each file. Each upload session is identified by a unique token.
 // @Tags Files
 // @Accept json
 // @Produce json
 // @Param input body PresignInput true "List of files to upload"
 // @Success 200 {object} utils.Payload{data=PresignResponse} "Presigned URLs generated successfully"
 // @Failure 400 {object} utils.Payload "Invalid input or size limit exceeded"
+// @Failure 413 {object} utils.Payload "Request body too large"
 // @Failure 405 {object} utils.Payload "Method not allowed"
 // @Failure 500 {object} utils.Payload "Failed to generate presigned URL"
 // @Router /api/v1/files/presign [post]
-func PresignUpload(w http.ResponseWriter, r *http.Request) {
+func (h *FileHandler) PresignUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		utils.JSONResponse(w, http.StatusMethodNotAllowed, utils.Payload{
-			Success: false,
-			Message: "Method not allowed",
-		})
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var input PresignInput
 
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(&input); err != nil {
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: "Invalid input",
-		})
+	if !decodeJSON(w, r, &input) {
 		return
 	}
 
-	// Calculate total size
-	var totalSize int64
+	files := make([]services.UploadFile, 0, len(input))
 	for _, f := range input {
-		totalSize += f.Size
-	}
-	if totalSize > maxUploadSize {
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: "Total file size exceeds 100 MB limit",
-		})
-		return
+		files = append(files, services.UploadFile{Filename: f.Filename, Size: f.Size})
 	}
 
-	token, err := utils.GenerateSecureToken(32)
+	session, err := h.transfers.CreateUploadSession(r.Context(), files)
 	if err != nil {
-		utils.JSONResponse(w, http.StatusInternalServerError, utils.Payload{
-			Success: false,
-			Message: "Failed to create transfer token",
-		})
+		h.transferError(w, r, err, "Failed to generate presigned URL")
 		return
 	}
 
-	results := make([]PresignedFile, 0, len(input))
-
-	for _, f := range input {
-		key := "uploads/" + token + "/" + uuid.New().String() + "_" + f.Filename
-		uploadURL, err := repositories.GeneratePresignedPutURL(context.Background(), key, 15*time.Minute)
-		if err != nil {
-			utils.JSONResponse(w, http.StatusInternalServerError, utils.Payload{
-				Success: false,
-				Message: "Failed to generate presigned URL",
-			})
-			return
-		}
+	results := make([]PresignedFile, 0, len(session.Uploads))
+	for _, u := range session.Uploads {
 		results = append(results, PresignedFile{
-			Filename:  f.Filename,
-			UploadURL: uploadURL,
-			Key:       key,
+			Filename:  u.Filename,
+			UploadURL: u.UploadURL,
+			Key:       u.Key,
 		})
 	}
 
@@ -130,7 +96,7 @@ func PresignUpload(w http.ResponseWriter, r *http.Request) {
 		Success: true,
 		Message: "Presigned URLs generated successfully",
 		Data: map[string]any{
-			"token": token,
+			"token": session.Token,
 			"urls":  results,
 		},
 	})
@@ -146,147 +112,49 @@ func PresignUpload(w http.ResponseWriter, r *http.Request) {
 // @Param input body CompleteUploadInput true "Upload completion payload"
 // @Success 200 {object} utils.Payload{data=map[string]interface{}} "Files uploaded successfully"
 // @Failure 400 {object} utils.Payload "Invalid input or verification failed"
+// @Failure 413 {object} utils.Payload "Request body too large"
 // @Failure 405 {object} utils.Payload "Method not allowed"
 // @Failure 500 {object} utils.Payload "Database error"
 // @Router /api/v1/files/complete [post]
-func CompleteUpload(w http.ResponseWriter, r *http.Request) {
+func (h *FileHandler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		utils.JSONResponse(w, http.StatusMethodNotAllowed, utils.Payload{
-			Success: false,
-			Message: "Method not allowed",
-		})
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var senderUUID *uuid.UUID
-	if val := r.Context().Value(middleware.UserIDKey); val != nil {
-		if idStr, ok := val.(string); ok && idStr != "" {
-			if parsedID, err := uuid.Parse(idStr); err == nil {
-				senderUUID = &parsedID
-			}
-		}
+	if id, ok := currentUserID(r); ok {
+		senderUUID = &id
 	}
 
 	var input CompleteUploadInput
 
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(&input); err != nil {
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: "Invalid input",
-		})
+	if !decodeJSON(w, r, &input) {
 		return
 	}
 
-	if input.Token == "" || len(input.Files) == 0 {
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: "Missing token or no files provided",
-		})
-		return
+	finalize := services.FinalizeInput{
+		Token:    input.Token,
+		SenderID: senderUUID,
 	}
-
-	ctx := context.Background()
-	g, ctx := errgroup.WithContext(ctx)
-
 	for _, f := range input.Files {
-		file := f
-		g.Go(func() error {
-			exists, err := repositories.VerifyObjectExists(ctx, file.Key)
-			if err != nil {
-				return fmt.Errorf("failed to verify %s: %w", file.Filename, err)
-			}
-			if !exists {
-				return fmt.Errorf("file not found: %s", file.Filename)
-			}
-			return nil
+		finalize.Files = append(finalize.Files, services.UploadedFile{
+			Filename:    f.Filename,
+			Size:        f.Size,
+			Key:         f.Key,
+			ContentType: f.ContentType,
 		})
 	}
-
-	if err := g.Wait(); err != nil {
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: err.Error(),
+	for _, rKey := range input.RecipientKeys {
+		finalize.Recipients = append(finalize.Recipients, services.RecipientKey{
+			PublicKey:    rKey.PublicKey,
+			EncryptedKey: rKey.EncryptedKey,
 		})
-		return
 	}
 
-	var TotalSize int64
-	for _, f := range input.Files {
-		TotalSize += f.Size
-	}
-
-	if TotalSize > maxUploadSize {
-		utils.JSONResponse(w, http.StatusBadRequest, utils.Payload{
-			Success: false,
-			Message: "Total upload size exceeds 100MB limit for anonymous transfers",
-		})
-		return
-	}
-
-	// Begin DB transaction
-	db := repositories.DB
-	err := db.Transaction(func(tx *gorm.DB) error {
-		// Create transfer record
-		// TDOD: Add user ID as sender ID
-		transfer := models.Transfer{
-			Token:       input.Token,
-			ExpiresAt:   time.Now().Add(1 * time.Hour),
-			IsAnonymous: senderUUID == nil,
-			SenderID: senderUUID,
-			TotalSize:   TotalSize,
-		}
-
-		if err := tx.Create(&transfer).Error; err != nil {
-			return err
-		}
-
-		// Store each file
-		for i, f := range input.Files {
-			file := models.File{
-				TransferID:  transfer.ID,
-				Filename:    f.Filename,
-				Size:        f.Size,
-				Path:        f.Key,
-				ContentType: f.ContentType,
-				Index:       i,
-			}
-			if err := tx.Create(&file).Error; err != nil {
-				return err
-			}
-		}
-
-		if len(input.RecipientKeys) > 0 {
-			for _, rKey := range input.RecipientKeys {
-				// look up the User ID using the Public Key
-				var user models.User
-				// Note: Public keys are text, so direct string comparison works
-				if err := tx.Select("id").Where("public_key = ?", rKey.PublicKey).First(&user).Error; err != nil {
-					// Option: Return error if user not found, OR skip them. 
-					// For security, failing is usually better to prevent partial sends.
-					return fmt.Errorf("recipient not found for provided public key")
-				}
-
-				recipient := models.Recipient{
-					TransferID:   transfer.ID,
-					ReceiverID:   user.ID,
-					EncryptedKey: rKey.EncryptedKey,
-				}
-				if err := tx.Create(&recipient).Error; err != nil {
-					return err
-				}
-			}
-		}
-
-		return nil
-	})
-
+	transfer, err := h.transfers.FinalizeTransfer(r.Context(), finalize)
 	if err != nil {
-		utils.JSONResponse(w, http.StatusInternalServerError, utils.Payload{
-			Success: false,
-			Message: "Failed to store files in DB",
-		})
+		h.transferError(w, r, err, "Failed to store files in DB")
 		return
 	}
 
@@ -294,8 +162,54 @@ func CompleteUpload(w http.ResponseWriter, r *http.Request) {
 		Success: true,
 		Message: "Files uploaded successfully",
 		Data: map[string]interface{}{
-			"share_code": input.Token,
-			"expires_in": "1h",
+			"share_code": transfer.Token,
+			"expires_in": shortDuration(h.transfers.TTL()),
 		},
 	})
 }
+
+// transferError responds with the status and message matching a
+// TransferService error. Unexpected errors are logged and reported with
+// fallback.
+func (h *handler) transferError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	if errors.Is(err, services.ErrTransferTooLarge) {
+		utils.ErrorResponse(w, utils.CodeQuotaExceeded,
+			fmt.Sprintf("Total upload size exceeds %d MB limit", h.Config.Transfers.MaxUploadSize>>20))
+		return
+	}
+	if m, ok := utils.MapError(err, transferErrors); ok {
+		utils.ErrorResponse(w, m.Code, m.Message)
+		return
+	}
+	h.Logger.ErrorContext(r.Context(), fallback, "error", err)
+	utils.ErrorResponse(w, utils.CodeInternal, fallback)
+}
+
+// transferErrors maps the errors of the transfer service to responses
+var transferErrors = []utils.ErrorMapping{
+	{Err: services.ErrEmptyTransfer, Code: utils.CodeInvalidInput, Message: "Missing token or no files provided"},
+	{Err: services.ErrTooManyFiles, Code: utils.CodeInvalidInput, Message: fmt.Sprintf("A transfer can hold at most %d files", services.MaxTransferFiles)},
+	// Names the missing file
+	{Err: services.ErrFileNotUploaded, Code: utils.CodeFileNotUploaded},
+	{Err: services.ErrInvalidFileKey, Code: utils.CodeInvalidInput, Message: "File key does not belong to this upload session"},
+	{Err: services.ErrRecipientNotFound, Code: utils.CodeRecipientNotFound, Message: "Recipient not found for provided public key"},
+	{Err: services.ErrRecipientUnverified, Code: utils.CodeRecipientUnverified, Message: "Recipient has not verified their email address"},
+	{Err: services.ErrTransferNotFound, Code: utils.CodeTransferNotFound, Message: "Invalid or expired share link"},
+	{Err: services.ErrTransferExpired, Code: utils.CodeTransferExpired, Message: "This link has expired"},
+	{Err: services.ErrNotRecipient, Code: utils.CodeNotARecipient, Message: "You are not an authorized recipient for this transfer"},
+	{Err: services.ErrRecipientKeysReset, Code: utils.CodeRecipientKeysReset, Message: "This transfer was encrypted for a key that has since been reset and can no longer be decrypted"},
+	{Err: services.ErrFileNotFound, Code: utils.CodeFileNotFound, Message: "File not found"},
+}
+
+// shortDuration formats d without trailing zero units, e.g. "1h" rather
+// than "1h0m0s".
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rohits-web03/obscyra/internal/api/services"
+	"github.com/rohits-web03/obscyra/internal/utils"
+)
+
+func TestPresignUploadFileCount(t *testing.T) {
+	files := func(n int) string {
+		items := make([]map[string]any, n)
+		for i := range items {
+			items[i] = map[string]any{"filename": "file.txt", "size": 1}
+		}
+		b, _ := json.Marshal(items)
+		return string(b)
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode utils.ErrorCode // empty for success
+	}{
+		{name: "one file", body: files(1)},
+		{name: "at the limit", body: files(services.MaxTransferFiles)},
+		{name: "no files", body: files(0), wantCode: utils.CodeInvalidInput},
+		{name: "too many files", body: files(services.MaxTransferFiles + 1), wantCode: utils.CodeInvalidInput},
+		{name: "not an array", body: `{"filename":"file.txt"}`, wantCode: utils.CodeInvalidInput},
+	}
+
+	a := newTestApp(t)
+	h := NewFileHandler(a, services.NewTransferService(a.Repos, a.Storage, a.Metrics, a.Config.Transfers, a.Now))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.PresignUpload(w, httptest.NewRequest(http.MethodPost, "/api/v1/files/presign", strings.NewReader(tt.body)))
+			payload := decodePayload(t, w)
+
+			if tt.wantCode == "" {
+				if w.Code != http.StatusOK {
+					t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+				}
+				return
+			}
+			if payload.Error == nil || payload.Error.Code != tt.wantCode {
+				t.Errorf("error %+v, want %s", payload.Error, tt.wantCode)
+			}
+		})
+	}
+}
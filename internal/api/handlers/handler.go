@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"github.com/rohits-web03/obscyra/internal/api/services"
+	"github.com/rohits-web03/obscyra/internal/app"
+)
+
+// handler gives the handler types access to the app's dependencies and holds
+// the helpers they share.
+type handler struct {
+	*app.App
+}
+
+// AuthHandler serves sign up, password login and the OAuth/OIDC login flows.
+type AuthHandler struct{ handler }
+
+func NewAuthHandler(a *app.App) *AuthHandler {
+	return &AuthHandler{handler{a}}
+}
+
+// AccountHandler serves email verification, password management and the
+// signed in user's linked identities.
+type AccountHandler struct{ handler }
+
+func NewAccountHandler(a *app.App) *AccountHandler {
+	return &AccountHandler{handler{a}}
+}
+
+// FileHandler serves the upload flow.
+type FileHandler struct {
+	handler
+	transfers *services.TransferService
+}
+
+func NewFileHandler(a *app.App, transfers *services.TransferService) *FileHandler {
+	return &FileHandler{handler{a}, transfers}
+}
+
+// ShareHandler serves shared transfers to their recipients.
+type ShareHandler struct {
+	handler
+	transfers *services.TransferService
+}
+
+func NewShareHandler(a *app.App, transfers *services.TransferService) *ShareHandler {
+	return &ShareHandler{handler{a}, transfers}
+}
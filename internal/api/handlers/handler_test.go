@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohits-web03/obscyra/internal/api/middleware"
+	"github.com/rohits-web03/obscyra/internal/api/services"
+	"github.com/rohits-web03/obscyra/internal/app"
+	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/rohits-web03/obscyra/internal/mailer"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/throttle"
+	"github.com/rohits-web03/obscyra/internal/tokens"
+	"github.com/rohits-web03/obscyra/internal/utils"
+)
+
+// newTestApp returns an App backed by the memory repositories and local
+// storage in a temporary directory.
+func newTestApp(t *testing.T) *app.App {
+	t.Helper()
+	cfg := config.Default()
+	cfg.JWTSecret = "test-secret"
+
+	storage, err := repositories.NewLocalStorage(t.TempDir(), cfg.BaseURL, cfg.JWTSecret, cfg.Transfers.MaxUploadSize, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := tokens.New(cfg.JWT, cfg.JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	logins, err := throttle.NewLogins(cfg.LoginThrottle, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Mail goes to a file, flushed before the directory is removed
+	mail := mailer.NewMailer(&mailer.LogSender{Path: filepath.Join(t.TempDir(), "mail.log")})
+	t.Cleanup(func() { mail.Wait(context.Background()) })
+
+	return &app.App{
+		Config:     cfg,
+		Repos:      repositories.NewMemoryRepositories(),
+		Storage:    storage,
+		Tokens:     keys,
+		Logger:     logger,
+		Mailer:     mail,
+		Providers:  services.NewIdentityProviders(cfg.Google, nil),
+		Logins:     logins,
+		RateLimits: middleware.NewMemoryRateLimitStore(),
+	}
+}
+
+// decodePayload decodes the JSON response recorded by w.
+func decodePayload(t *testing.T, w *httptest.ResponseRecorder) utils.Payload {
+	t.Helper()
+	var payload utils.Payload
+	if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decoding response %q: %v", w.Body.String(), err)
+	}
+	return payload
+}
+
+// TestSessionCookieFollowsClock checks the cookie lifetime is the session
+// TTL whatever time the injected clock reports.
+func TestSessionCookieFollowsClock(t *testing.T) {
+	a := newTestApp(t)
+	a.Clock = func() time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) }
+	user := &models.User{ID: uuid.New(), Username: "alice"}
+
+	w := httptest.NewRecorder()
+	if err := (&handler{a}).setSessionCookie(w, user); err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].MaxAge != int(a.Config.SessionTTL.Seconds()) {
+		t.Errorf("cookies = %+v, want MaxAge %d", cookies, int(a.Config.SessionTTL.Seconds()))
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/utils"
+)
+
+// GET /api/v1/me/identities
+// ListIdentities godoc
+// @Summary List linked identities
+// @Description Returns the external identity providers linked to the signed in account and whether a password is set.
+// @Tags Account
+// @Produce json
+// @Success 200 {object} utils.Payload "Identities retrieved successfully"
+// @Failure 401 {object} utils.Payload "Unauthorized"
+// @Router /api/v1/me/identities [get]
+func (h *AccountHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := currentUserID(r)
+	if !ok {
+		utils.ErrorResponse(w, utils.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.Repos.Users.GetByID(r.Context(), userID)
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	identities, err := h.Repos.Identities.ListByUser(r.Context(), userID)
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "Identities retrieved successfully",
+		Data: map[string]any{
+			"has_password": user.Password != "",
+			"identities":   identities,
+		},
+	})
+}
+
+// GET /api/v1/me/identities/{provider}/link
+// LinkIdentity godoc
+// @Summary Link an identity provider
+// @Description Redirects the signed in user's browser to the provider. After consent the provider identity is linked to the account and the browser is sent to return_to or the frontend settings page.
+// @Tags Account
+// @Param provider path string true "Provider name (google or a configured OIDC provider)"
+// @Param return_to query string false "Frontend path or allowed URL to return to after linking"
+// @Success 307 "Redirect to identity provider"
+// @Failure 401 {object} utils.Payload "Unauthorized"
+// @Router /api/v1/me/identities/{provider}/link [get]
+func (h *AccountHandler) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		utils.ErrorResponse(w, utils.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	h.startOAuthFlow(w, r, r.PathValue("provider"), map[string]string{
+		"flow": "link",
+		"uid":  userID.String(),
+	})
+}
+
+// DELETE /api/v1/me/identities/{provider}
+// UnlinkIdentity godoc
+// @Summary Unlink an identity provider
+// @Description Removes a linked provider identity. The last sign-in method of an account can't be removed.
+// @Tags Account
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} utils.Payload "Identity unlinked successfully"
+// @Failure 401 {object} utils.Payload "Unauthorized"
+// @Failure 404 {object} utils.Payload "Identity not linked"
+// @Failure 409 {object} utils.Payload "Cannot remove the only sign-in method"
+// @Router /api/v1/me/identities/{provider} [delete]
+func (h *AccountHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := currentUserID(r)
+	if !ok {
+		utils.ErrorResponse(w, utils.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	provider := r.PathValue("provider")
+
+	// code is left empty when the identity was unlinked
+	var code utils.ErrorCode
+	var message string
+	ctx := r.Context()
+	err := h.Repos.Transaction(ctx, func(tx repositories.Repositories) error {
+		// Lock the user row so concurrent unlinks can't remove every sign-in method
+		user, err := tx.Users.GetForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		identities, err := tx.Identities.ListByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		var target *models.Identity
+		for i := range identities {
+			if identities[i].Provider == provider {
+				target = &identities[i]
+			}
+		}
+
+		switch {
+		case target == nil:
+			code, message = utils.CodeIdentityNotLinked, "Identity not linked"
+			return nil
+		case user.Password == "" && len(identities) == 1:
+			code, message = utils.CodeLastSignInMethod, "Cannot remove the only sign-in method of this account"
+			return nil
+		}
+
+		return tx.Identities.Delete(ctx, target.ID)
+	})
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+
+	if code != "" {
+		utils.ErrorResponse(w, code, message)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "Identity unlinked successfully",
+	})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// jwksMaxAge is how long verifiers may cache the key set. Keys must be
+// published at least this long before they start signing.
+const jwksMaxAge = "max-age=300"
+
+// GET /.well-known/jwks.json
+// JWKS godoc
+// @Summary Session token verification keys
+// @Description Returns the public keys session JWTs are signed with as a JSON Web Key Set (RFC 7517), so other services can verify them. Tokens name their key in the kid header. The set is empty when tokens are signed with the shared HS256 secret.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} tokens.JWKS "Key set"
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, "+jwksMaxAge)
+	if err := json.NewEncoder(w).Encode(h.Tokens.JWKS()); err != nil {
+		h.Logger.ErrorContext(r.Context(), "Failed to encode JWKS", "error", err)
+	}
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/utils"
+	"github.com/rohits-web03/obscyra/internal/validation"
+)
+
+const googleProvider = "google"
+
+// oauthProfile is the identity asserted by an external provider after a
+// successful callback.
+type oauthProfile struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// loginFlow normalizes the public "redirect" parameter; linking can only be
+// started from an authenticated session.
+func loginFlow(r *http.Request) string {
+	if r.URL.Query().Get("redirect") == "register" {
+		return "register"
+	}
+	return "login"
+}
+
+// startOAuthFlow redirects the browser to the provider's authorization page.
+// The flow metadata, including the validated return_to destination, travels
+// in a signed state that is also bound to the browser through a cookie.
+func (h *handler) startOAuthFlow(w http.ResponseWriter, r *http.Request, providerName string, data map[string]string) {
+	data["provider"] = providerName
+
+	returnTo, ok := h.resolveReturnTo(r.URL.Query().Get("return_to"))
+	if !ok {
+		h.failOAuthFlow(w, r, data, errInvalidReturnTo)
+		return
+	}
+	if returnTo != "" {
+		data["return_to"] = returnTo
+	}
+
+	if providerName == googleProvider {
+		state, err := h.generateState(data)
+		if err != nil {
+			h.Logger.ErrorContext(r.Context(), "Failed to generate OAuth state", "error", err)
+			h.failOAuthFlow(w, r, data, errServerError)
+			return
+		}
+		h.setStateCookie(w, state)
+		http.Redirect(w, r, h.Providers.Google.AuthCodeURL(state), http.StatusTemporaryRedirect)
+		return
+	}
+
+	provider, ok := h.Providers.OIDC(providerName)
+	if !ok {
+		h.failOAuthFlow(w, r, data, errUnknownProvider)
+		return
+	}
+
+	oauthConfig, _, err := provider.Discover(r.Context())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "OIDC discovery failed", "provider", providerName, "error", err)
+		h.failOAuthFlow(w, r, data, errProviderUnavailable)
+		return
+	}
+
+	nonce, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "Failed to generate OAuth state", "error", err)
+		h.failOAuthFlow(w, r, data, errServerError)
+		return
+	}
+	data["nonce"] = nonce
+
+	state, err := h.generateState(data)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "Failed to generate OAuth state", "error", err)
+		h.failOAuthFlow(w, r, data, errServerError)
+		return
+	}
+	h.setStateCookie(w, state)
+
+	http.Redirect(w, r, oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce)), http.StatusTemporaryRedirect)
+}
+
+// verifyCallbackState checks the state returned to a provider callback and
+// returns its metadata.
+func (h *handler) verifyCallbackState(w http.ResponseWriter, r *http.Request, providerName string) (map[string]string, bool) {
+	state := r.FormValue("state")
+	if !h.checkStateCookie(w, r, state) {
+		return nil, false
+	}
+	stateData, err := h.decodeState(state)
+	if err != nil || stateData["provider"] != providerName {
+		return nil, false
+	}
+	return stateData, true
+}
+
+// completeOAuthFlow finishes a login, registration or account link for an
+// identity verified by a provider. Users are matched on the provider subject;
+// an email match alone never signs anyone into an existing account, except
+// for adopting the legacy Google accounts described below.
+func (h *handler) completeOAuthFlow(w http.ResponseWriter, r *http.Request, stateData map[string]string, profile oauthProfile) {
+	flowType := stateData["flow"]
+	ctx := r.Context()
+
+	identity, err := h.Repos.Identities.GetBySubject(ctx, profile.Provider, profile.Subject)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		h.Logger.ErrorContext(ctx, "OAuth flow database error", "error", err)
+		h.failOAuthFlow(w, r, stateData, errServerError)
+		return
+	}
+	linked := err == nil
+
+	if flowType == "link" {
+		h.linkIdentity(w, r, stateData, identity, profile)
+		return
+	}
+
+	var user models.User
+	switch {
+	case linked:
+		if flowType == "register" {
+			h.failOAuthFlow(w, r, stateData, errUserAlreadyExists)
+			return
+		}
+		found, err := h.Repos.Users.GetByID(ctx, identity.UserID)
+		if err != nil {
+			h.Logger.ErrorContext(ctx, "OAuth flow database error", "error", err)
+			h.failOAuthFlow(w, r, stateData, errServerError)
+			return
+		}
+		user = *found
+
+	default:
+		existing, err := h.Repos.Users.GetByEmail(ctx, profile.Email)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			h.Logger.ErrorContext(ctx, "OAuth flow database error", "error", err)
+			h.failOAuthFlow(w, r, stateData, errServerError)
+			return
+		}
+
+		if err == nil {
+			// Accounts created through Google before identities existed have
+			// no password and no identities; adopt them on their first Google
+			// login. Other providers never created such accounts, so their
+			// email claim can't claim one.
+			if profile.Provider == googleProvider && profile.EmailVerified && existing.Password == "" {
+				identities, err := h.Repos.Identities.ListByUser(ctx, existing.ID)
+				if err != nil {
+					h.Logger.ErrorContext(ctx, "OAuth flow database error", "error", err)
+					h.failOAuthFlow(w, r, stateData, errServerError)
+					return
+				}
+				if len(identities) == 0 {
+					err := h.Repos.Transaction(ctx, func(tx repositories.Repositories) error {
+						if err := tx.Identities.Create(ctx, &models.Identity{
+							UserID:   existing.ID,
+							Provider: profile.Provider,
+							Subject:  profile.Subject,
+							Email:    profile.Email,
+						}); err != nil {
+							return err
+						}
+						adopted, err := tx.Users.GetForUpdate(ctx, existing.ID)
+						if err != nil {
+							return err
+						}
+						adopted.EmailVerified = true
+						if err := tx.Users.Update(ctx, adopted); err != nil {
+							return err
+						}
+						user = *adopted
+						return nil
+					})
+					if err != nil {
+						h.Logger.ErrorContext(ctx, "Failed to link identity", "error", err)
+						h.failOAuthFlow(w, r, stateData, errServerError)
+						return
+					}
+					break
+				}
+			}
+
+			// An account with this email exists but this identity isn't
+			// linked to it: the owner has to sign in and link it explicitly
+			h.failOAuthFlow(w, r, stateData, errAccountExists)
+			return
+		}
+
+		if flowType != "register" {
+			h.failOAuthFlow(w, r, stateData, errUserNotFound)
+			return
+		}
+
+		if profile.Email == "" || !profile.EmailVerified {
+			h.failOAuthFlow(w, r, stateData, errEmailNotVerified)
+			return
+		}
+
+		username, err := h.availableUsername(ctx, profile.PreferredUsername, profile.Name, profile.Email)
+		if err != nil {
+			h.Logger.ErrorContext(ctx, "OAuth flow database error", "error", err)
+			h.failOAuthFlow(w, r, stateData, errServerError)
+			return
+		}
+
+		user = models.User{
+			Username:      username,
+			Email:         profile.Email,
+			Password:      "", // authenticated through the identity provider
+			EmailVerified: true,
+		}
+		err = h.Repos.Transaction(ctx, func(tx repositories.Repositories) error {
+			if err := tx.Users.Create(ctx, &user); err != nil {
+				return err
+			}
+			return tx.Identities.Create(ctx, &models.Identity{
+				UserID:   user.ID,
+				Provider: profile.Provider,
+				Subject:  profile.Subject,
+				Email:    profile.Email,
+			})
+		})
+		if err != nil {
+			h.Logger.ErrorContext(ctx, "Failed to create OAuth user", "error", err)
+			h.failOAuthFlow(w, r, stateData, errServerError)
+			return
+		}
+	}
+
+	if err := h.setSessionCookie(w, &user); err != nil {
+		h.Logger.ErrorContext(ctx, "Failed to create session", "error", err)
+		h.failOAuthFlow(w, r, stateData, errServerError)
+		return
+	}
+
+	status := "success_login"
+	if flowType == "register" {
+		status = "success_register"
+	}
+	h.finishOAuthFlow(w, r, stateData, "/share/send", status)
+}
+
+// linkIdentity attaches a verified provider identity to the signed in user
+// who started the link flow. identity is the existing link of the provider
+// subject, if any.
+func (h *handler) linkIdentity(w http.ResponseWriter, r *http.Request, stateData map[string]string, identity *models.Identity, profile oauthProfile) {
+	ctx := r.Context()
+	userID, err := uuid.Parse(stateData["uid"])
+	if err != nil {
+		h.failOAuthFlow(w, r, stateData, errInvalidState)
+		return
+	}
+
+	if identity != nil {
+		if identity.UserID != userID {
+			h.failOAuthFlow(w, r, stateData, errIdentityInUse)
+			return
+		}
+		h.finishOAuthFlow(w, r, stateData, "/settings", "identity_linked")
+		return
+	}
+
+	identities, err := h.Repos.Identities.ListByUser(ctx, userID)
+	if err != nil {
+		h.Logger.ErrorContext(ctx, "OAuth flow database error", "error", err)
+		h.failOAuthFlow(w, r, stateData, errServerError)
+		return
+	}
+	for _, linked := range identities {
+		if linked.Provider == profile.Provider {
+			h.failOAuthFlow(w, r, stateData, errProviderAlreadyLinked)
+			return
+		}
+	}
+
+	if err := h.Repos.Identities.Create(ctx, &models.Identity{
+		UserID:   userID,
+		Provider: profile.Provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	}); err != nil {
+		h.Logger.ErrorContext(ctx, "Failed to link identity", "error", err)
+		h.failOAuthFlow(w, r, stateData, errServerError)
+		return
+	}
+
+	h.finishOAuthFlow(w, r, stateData, "/settings", "identity_linked")
+}
+
+// availableUsername picks a username from the provider claims, appending a
+// random suffix when the preferred one is already taken. Claims are cut to
+// the characters and length accepted at registration.
+func (h *handler) availableUsername(ctx context.Context, candidates ...string) (string, error) {
+	base := "user"
+	for _, c := range candidates {
+		c, _, _ = strings.Cut(c, "@")
+		c = strings.Map(func(r rune) rune {
+			if validation.UsernameRune(r) {
+				return r
+			}
+			return -1
+		}, c)
+		if len(c) >= validation.MinUsernameLength {
+			base = c
+			break
+		}
+	}
+
+	username := truncateUsername(base, 0)
+	for range 5 {
+		taken, err := h.Repos.Users.UsernameExists(ctx, username)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return username, nil
+		}
+
+		suffix, err := utils.GenerateSecureToken(3)
+		if err != nil {
+			return "", err
+		}
+		suffix = "-" + strings.ToLower(suffix)
+		username = truncateUsername(base, len(suffix)) + suffix
+	}
+	return "", errors.New("could not find an available username")
+}
+
+// truncateUsername cuts base so a suffix of n bytes still fits in a
+// username. Usernames are ASCII, so bytes are characters.
+func truncateUsername(base string, n int) string {
+	if limit := validation.MaxUsernameLength - n; len(base) > limit {
+		return base[:limit]
+	}
+	return base
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rohits-web03/obscyra/internal/app"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/validation"
+)
+
+func TestAvailableUsername(t *testing.T) {
+	long := strings.Repeat("a", 40)
+
+	tests := []struct {
+		name       string
+		candidates []string
+		want       string
+		wantPrefix string // when a suffix is appended
+	}{
+		{name: "preferred username", candidates: []string{"alice", "Alice Smith", "alice@example.com"}, want: "alice"},
+		{name: "invalid characters dropped", candidates: []string{"", "Alice Smith", ""}, want: "AliceSmith"},
+		{name: "non-ASCII dropped", candidates: []string{"José Müller"}, want: "JosMller"},
+		{name: "email local part", candidates: []string{"", "", "bob+tag@example.com"}, want: "bobtag"},
+		{name: "too short skipped", candidates: []string{"jo", "Jo Ann", ""}, want: "JoAnn"},
+		{name: "nothing usable", candidates: []string{"", "李", "@example.com"}, want: "user"},
+		{name: "truncated", candidates: []string{strings.Repeat("b", 40)}, want: strings.Repeat("b", validation.MaxUsernameLength)},
+		{name: "taken gets suffix", candidates: []string{"taken"}, wantPrefix: "taken-"},
+		{name: "taken long gets suffix within limit", candidates: []string{long}, wantPrefix: long[:validation.MaxUsernameLength-5] + "-"},
+	}
+
+	repos := repositories.NewMemoryRepositories()
+	for _, name := range []string{"taken", long[:validation.MaxUsernameLength]} {
+		user := &models.User{Username: name, Email: name + "@example.com", Password: "x"}
+		if err := repos.Users.Create(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &handler{&app.App{Repos: repos}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.availableUsername(context.Background(), tt.candidates...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantPrefix != "" {
+				if !strings.HasPrefix(got, tt.wantPrefix) || got == tt.wantPrefix {
+					t.Errorf("got %q, want %q with a suffix", got, tt.wantPrefix)
+				}
+			} else if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+
+			if errs := validation.Validate(struct {
+				Username string `json:"username" validate:"required,min=3,max=32,username"`
+			}{got}); len(errs) > 0 {
+				t.Errorf("%q fails validation: %v", got, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// GET /api/v1/auth/oidc/{provider}/login
+// HandleOIDCLogin starts an authorization code flow with a configured OIDC provider.
+// @Summary Start OIDC login
+// @Description Redirects the browser to the identity provider's authorization endpoint. Use redirect=register to create an account on callback. Failures redirect to the frontend with an error code in the error query parameter.
+// @Tags Auth
+// @Param provider path string true "Provider name"
+// @Param redirect query string false "login (default) or register"
+// @Param return_to query string false "Frontend path or allowed URL to return to after signing in"
+// @Success 307 "Redirect to identity provider"
+// @Router /api/v1/auth/oidc/{provider}/login [get]
+func (h *AuthHandler) HandleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	h.startOAuthFlow(w, r, r.PathValue("provider"), map[string]string{"flow": loginFlow(r)})
+}
+
+// GET /api/v1/auth/oidc/{provider}/callback
+// HandleOIDCCallback completes an OIDC login, verifying the ID token against the provider's JWKS.
+// @Summary OIDC callback
+// @Description Exchanges the authorization code, verifies the ID token and signs the user in, then redirects to return_to or the frontend. Failures redirect to the frontend with an error code in the error query parameter.
+// @Tags Auth
+// @Param provider path string true "Provider name"
+// @Param state query string true "OAuth state"
+// @Param code query string true "Authorization code"
+// @Success 307 "Redirect to frontend"
+// @Router /api/v1/auth/oidc/{provider}/callback [get]
+func (h *AuthHandler) HandleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.Providers.OIDC(r.PathValue("provider"))
+	if !ok {
+		h.failOAuthFlow(w, r, nil, errUnknownProvider)
+		return
+	}
+
+	stateData, ok := h.verifyCallbackState(w, r, provider.Name)
+	if !ok {
+		h.failOAuthFlow(w, r, nil, errInvalidState)
+		return
+	}
+
+	// The provider reports failures such as a denied consent via the error parameter
+	if code, failed := h.providerError(r); failed {
+		h.failOAuthFlow(w, r, stateData, code)
+		return
+	}
+
+	oauthConfig, verifier, err := provider.Discover(r.Context())
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "OIDC discovery failed", "provider", provider.Name, "error", err)
+		h.failOAuthFlow(w, r, stateData, errProviderUnavailable)
+		return
+	}
+
+	token, err := oauthConfig.Exchange(r.Context(), r.FormValue("code"))
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "OIDC code exchange failed", "provider", provider.Name, "error", err)
+		h.failOAuthFlow(w, r, stateData, errProviderError)
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		h.Logger.ErrorContext(r.Context(), "OIDC provider did not return an ID token", "provider", provider.Name)
+		h.failOAuthFlow(w, r, stateData, errProviderError)
+		return
+	}
+
+	idToken, err := verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		h.Logger.WarnContext(r.Context(), "OIDC ID token verification failed", "provider", provider.Name, "error", err)
+		h.failOAuthFlow(w, r, stateData, errProviderError)
+		return
+	}
+	if idToken.Nonce != stateData["nonce"] {
+		h.Logger.WarnContext(r.Context(), "OIDC ID token nonce mismatch", "provider", provider.Name)
+		h.failOAuthFlow(w, r, stateData, errInvalidState)
+		return
+	}
+
+	var claims struct {
+		Email             string    `json:"email"`
+		EmailVerified     claimBool `json:"email_verified"`
+		Name              string    `json:"name"`
+		PreferredUsername string    `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		h.Logger.ErrorContext(r.Context(), "Failed to parse ID token claims", "provider", provider.Name, "error", err)
+		h.failOAuthFlow(w, r, stateData, errProviderError)
+		return
+	}
+
+	h.completeOAuthFlow(w, r, stateData, oauthProfile{
+		Provider:          provider.Name,
+		Subject:           idToken.Subject,
+		Email:             claims.Email,
+		EmailVerified:     bool(claims.EmailVerified),
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	})
+}
+
+// claimBool decodes a boolean claim sent either as a JSON boolean or as a
+// string, as some providers (e.g. Amazon Cognito) do for email_verified.
+// Anything but true or "true" is false.
+type claimBool bool
+
+func (b *claimBool) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case bool:
+		*b = claimBool(v)
+	case string:
+		*b = claimBool(strings.EqualFold(v, "true"))
+	default:
+		*b = false
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rohits-web03/obscyra/internal/api/services"
+	"github.com/rohits-web03/obscyra/internal/config"
+)
+
+// testIdP is an OpenID provider serving discovery, its JWKS and a token
+// endpoint that returns an ID token with the claims of the current test.
+type testIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	// claims returns the ID token claims for the nonce of the flow
+	claims func(nonce string) jwt.MapClaims
+	// signer signs ID tokens; nil means key
+	signer *rsa.PrivateKey
+	nonce  string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims(idp.nonce))
+		token.Header["kid"] = "test"
+		signer := idp.signer
+		if signer == nil {
+			signer = idp.key
+		}
+		idToken, err := token.SignedString(signer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+			"id_token":     idToken,
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// validClaims are the claims of a well-formed ID token for the flow
+func (idp *testIdP) validClaims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":                idp.URL,
+		"sub":                "subject-1",
+		"aud":                "client",
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              nonce,
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+	}
+}
+
+func TestOIDCLoginAndCallback(t *testing.T) {
+	idp := newTestIdP(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(key string, value any) func(string) jwt.MapClaims {
+		return func(nonce string) jwt.MapClaims {
+			claims := idp.validClaims(nonce)
+			claims[key] = value
+			return claims
+		}
+	}
+
+	tests := []struct {
+		name       string
+		claims     func(nonce string) jwt.MapClaims
+		signer     *rsa.PrivateKey
+		callback   url.Values // extra callback parameters
+		noCookie   bool
+		wantError  oauthError
+		wantStatus string
+	}{
+		{name: "registers the user", claims: idp.validClaims, wantStatus: "success_register"},
+		{name: "wrong issuer", claims: with("iss", "https://evil.example.com"), wantError: errProviderError},
+		{name: "wrong audience", claims: with("aud", "other-client"), wantError: errProviderError},
+		{name: "expired", claims: with("exp", time.Now().Add(-time.Minute).Unix()), wantError: errProviderError},
+		{name: "signed with another key", claims: idp.validClaims, signer: otherKey, wantError: errProviderError},
+		{name: "nonce mismatch", claims: with("nonce", "other"), wantError: errInvalidState},
+		{name: "unverified email", claims: with("email_verified", false), wantError: errEmailNotVerified},
+		// Amazon Cognito sends email_verified as a string
+		{name: "verified email as a string", claims: with("email_verified", "true"), wantStatus: "success_register"},
+		{name: "unverified email as a string", claims: with("email_verified", "false"), wantError: errEmailNotVerified},
+		{name: "email_verified null", claims: with("email_verified", nil), wantError: errEmailNotVerified},
+		{name: "state cookie missing", claims: idp.validClaims, noCookie: true, wantError: errInvalidState},
+		{name: "consent denied", claims: idp.validClaims, callback: url.Values{"error": {"access_denied"}}, wantError: errAccessDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(t)
+			a.Providers = services.NewIdentityProviders(a.Config.Google, []config.OIDCProviderConfig{{
+				Name:         "test",
+				IssuerURL:    idp.URL,
+				ClientID:     "client",
+				ClientSecret: "secret",
+				RedirectURL:  "http://localhost:8080/api/v1/auth/oidc/test/callback",
+				Scopes:       []string{"openid", "email", "profile"},
+			}})
+			h := NewAuthHandler(a)
+			idp.claims, idp.signer = tt.claims, tt.signer
+
+			// Start the flow and follow the redirect to the provider
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/test/login?redirect=register", nil)
+			r.SetPathValue("provider", "test")
+			h.HandleOIDCLogin(w, r)
+			if w.Code != http.StatusTemporaryRedirect {
+				t.Fatalf("login returned %d, want a redirect", w.Code)
+			}
+			authorize, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := authorize.Scheme + "://" + authorize.Host + authorize.Path; got != idp.URL+"/authorize" {
+				t.Fatalf("redirected to %s, want the authorization endpoint", got)
+			}
+			state := authorize.Query().Get("state")
+			idp.nonce = authorize.Query().Get("nonce")
+			cookies := w.Result().Cookies()
+
+			// Come back from the provider
+			query := url.Values{"state": {state}, "code": {"code"}}
+			for k, v := range tt.callback {
+				query[k] = v
+			}
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/test/callback?"+query.Encode(), nil)
+			r.SetPathValue("provider", "test")
+			if !tt.noCookie {
+				for _, c := range cookies {
+					r.AddCookie(c)
+				}
+			}
+			h.HandleOIDCCallback(w, r)
+
+			location, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := oauthError(location.Query().Get("error")); got != tt.wantError {
+				t.Errorf("error = %q, want %q", got, tt.wantError)
+			}
+			if got := location.Query().Get("status"); got != tt.wantStatus {
+				t.Errorf("status = %q, want %q", got, tt.wantStatus)
+			}
+
+			_, err = a.Repos.Identities.GetBySubject(context.Background(), "test", "subject-1")
+			if registered := err == nil; registered != (tt.wantStatus != "") {
+				t.Errorf("identity stored = %v, want %v", registered, tt.wantStatus != "")
+			}
+		})
+	}
+}
+
+func TestOIDCUnknownProvider(t *testing.T) {
+	h := NewAuthHandler(newTestApp(t))
+
+	for _, path := range []string{"login", "callback"} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/missing/"+path, nil)
+			r.SetPathValue("provider", "missing")
+			if path == "login" {
+				h.HandleOIDCLogin(w, r)
+			} else {
+				h.HandleOIDCCallback(w, r)
+			}
+
+			location, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := oauthError(location.Query().Get("error")); got != errUnknownProvider {
+				t.Errorf("error = %q, want %q", got, errUnknownProvider)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// maxReturnToLength keeps the state, and the cookie holding it, small
+const maxReturnToLength = 1024
+
+// oauthError is the code sent to the frontend in the error query parameter
+// when an OAuth login, registration or link fails. The frontend maps codes
+// to messages, so they must stay stable.
+type oauthError string
+
+const (
+	errInvalidState          oauthError = "invalid_state"
+	errInvalidReturnTo       oauthError = "invalid_return_to"
+	errUnknownProvider       oauthError = "unknown_provider"
+	errProviderUnavailable   oauthError = "provider_unavailable"
+	errAccessDenied          oauthError = "access_denied"
+	errProviderError         oauthError = "provider_error"
+	errServerError           oauthError = "server_error"
+	errUserAlreadyExists     oauthError = "user_already_exists"
+	errUserNotFound          oauthError = "user_not_found"
+	errEmailNotVerified      oauthError = "email_not_verified"
+	errAccountExists         oauthError = "account_exists_link_required"
+	errIdentityInUse         oauthError = "identity_in_use"
+	errProviderAlreadyLinked oauthError = "provider_already_linked"
+)
+
+// failOAuthFlow sends the browser back to the frontend page the flow started
+// from with an error code. stateData may be nil when the state couldn't be
+// verified.
+func (h *handler) failOAuthFlow(w http.ResponseWriter, r *http.Request, stateData map[string]string, code oauthError) {
+	page := "/login"
+	switch stateData["flow"] {
+	case "register":
+		page = "/register"
+	case "link":
+		page = "/settings"
+	}
+
+	query := url.Values{"error": {string(code)}}
+	if provider := stateData["provider"]; provider != "" {
+		query.Set("provider", provider)
+	}
+	h.redirectToFrontend(w, r, page, query)
+}
+
+// finishOAuthFlow sends the browser to the return_to destination the flow was
+// started with, or to page on the frontend, reporting status.
+func (h *handler) finishOAuthFlow(w http.ResponseWriter, r *http.Request, stateData map[string]string, page, status string) {
+	query := url.Values{"status": {status}}
+
+	// Checked again in case the allowlist changed while the user was away
+	if returnTo, ok := h.resolveReturnTo(stateData["return_to"]); ok && returnTo != "" {
+		redirectTo(w, r, returnTo, query)
+		return
+	}
+	h.redirectToFrontend(w, r, page, query)
+}
+
+// providerError maps the error an authorization server reports on its
+// callback (RFC 6749 section 4.1.2.1) to an error code.
+func (h *handler) providerError(r *http.Request) (oauthError, bool) {
+	switch r.FormValue("error") {
+	case "":
+		return "", false
+	case "access_denied":
+		return errAccessDenied, true
+	default:
+		h.Logger.WarnContext(r.Context(), "Identity provider returned an error",
+			"error", r.FormValue("error"), "description", r.FormValue("error_description"))
+		return errProviderError, true
+	}
+}
+
+// resolveReturnTo validates the return_to destination requested when a flow
+// starts and returns it as an absolute URL. Paths are resolved against the
+// frontend; absolute URLs must fall under the frontend URL or one of the
+// configured oauth.allowed_return_urls. An empty value is valid and means the
+// default page.
+func (h *handler) resolveReturnTo(raw string) (string, bool) {
+	if raw == "" {
+		return "", true
+	}
+	if len(raw) > maxReturnToLength {
+		return "", false
+	}
+	// Browsers treat backslashes like slashes, so "/\evil.com" would leave
+	// the frontend
+	if strings.ContainsAny(raw, "\\\r\n\t") {
+		return "", false
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.User != nil || u.Opaque != "" {
+		return "", false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		if !strings.HasPrefix(u.Path, "/") {
+			return "", false
+		}
+		base, err := url.Parse(h.Config.FrontendURL)
+		if err != nil {
+			return "", false
+		}
+		u.Scheme, u.Host = base.Scheme, base.Host
+		u.Path = base.Path + u.Path
+	}
+
+	// Resolve dot segments before matching so "/app/../admin" can't escape
+	// an allowed prefix
+	if u.Path != "" {
+		cleaned := path.Clean(u.Path)
+		if strings.HasSuffix(u.Path, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		u.Path, u.RawPath = cleaned, ""
+	}
+	u.Fragment = ""
+
+	allowed := append([]string{h.Config.FrontendURL}, h.Config.OAuth.AllowedReturnURLs...)
+	for _, prefix := range allowed {
+		if matchesURLPrefix(u, prefix) {
+			return u.String(), true
+		}
+	}
+	return "", false
+}
+
+// matchesURLPrefix reports whether u has the same origin as prefix and a path
+// at or below prefix's path.
+func matchesURLPrefix(u *url.URL, prefix string) bool {
+	p, err := url.Parse(prefix)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(u.Scheme, p.Scheme) || !strings.EqualFold(u.Host, p.Host) {
+		return false
+	}
+	base := strings.TrimSuffix(p.Path, "/")
+	return base == "" || u.Path == base || strings.HasPrefix(u.Path, base+"/")
+}
+
+// redirectToFrontend redirects the browser to a path on the configured frontend.
+func (h *handler) redirectToFrontend(w http.ResponseWriter, r *http.Request, path string, query url.Values) {
+	redirectTo(w, r, h.Config.FrontendURL+path, query)
+}
+
+// redirectTo redirects the browser to target, adding query to any query the
+// target already has.
+func redirectTo(w http.ResponseWriter, r *http.Request, target string, query url.Values) {
+	u, err := url.Parse(target)
+	if err != nil {
+		http.Error(w, "Invalid redirect target", http.StatusInternalServerError)
+		return
+	}
+	q := u.Query()
+	for key, values := range query {
+		q[key] = values
+	}
+	u.RawQuery = q.Encode()
+	http.Redirect(w, r, u.String(), http.StatusTemporaryRedirect)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rohits-web03/obscyra/internal/utils"
+	"github.com/rohits-web03/obscyra/internal/validation"
+)
+
+// maxBodyBytes bounds JSON request bodies. The largest legitimate bodies are
+// upload completions listing many files and recipients.
+const maxBodyBytes = 1 << 20
+
+// decodeJSON reads the JSON request body into dst and validates it against
+// its `validate` tags. When the body is too large, malformed or invalid it
+// responds with the problems found and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		decodeError(w, err)
+		return false
+	}
+	if dec.More() {
+		utils.ErrorResponse(w, utils.CodeInvalidInput, "Request body must contain a single JSON value")
+		return false
+	}
+
+	if details := validation.Validate(dst); len(details) > 0 {
+		utils.ErrorResponse(w, utils.CodeInvalidInput, "Invalid input", details...)
+		return false
+	}
+	return true
+}
+
+// decodeError reports why a request body couldn't be decoded
+func decodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		utils.ErrorResponse(w, utils.CodePayloadTooLarge,
+			fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))
+	case errors.As(err, &typeErr) && typeErr.Field == "":
+		utils.ErrorResponse(w, utils.CodeInvalidInput, "Request body must be "+jsonType(typeErr.Type.Kind().String()))
+	case errors.As(err, &typeErr):
+		utils.ErrorResponse(w, utils.CodeInvalidInput, "Invalid input",
+			utils.FieldError{Field: typeErr.Field, Message: "must be " + jsonType(typeErr.Type.Kind().String())})
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		utils.ErrorResponse(w, utils.CodeInvalidInput, "Request body is not valid JSON")
+	case errors.Is(err, io.EOF):
+		utils.ErrorResponse(w, utils.CodeInvalidInput, "Request body is empty")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		utils.ErrorResponse(w, utils.CodeInvalidInput, "Invalid input",
+			utils.FieldError{Field: field, Message: "is not allowed"})
+	default:
+		utils.ErrorResponse(w, utils.CodeInvalidInput, "Invalid input")
+	}
+}
+
+// jsonType names a Go kind the way clients know it, with its article
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "bool":
+		return "a boolean"
+	case kind == "slice", kind == "array":
+		return "an array"
+	case kind == "struct", kind == "map":
+		return "an object"
+	}
+	return "a " + kind
+}
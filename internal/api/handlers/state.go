@@ -1,15 +1,20 @@
 package handlers
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 )
 
-// GenerateState creates a random state string containing optional metadata (e.g., "login" or "register")
-func GenerateState(data map[string]string) (string, error) {
+// generateState creates a random state string containing optional metadata (e.g., "login" or "register")
+func (h *handler) generateState(data map[string]string) (string, error) {
 	// Generate 16 random bytes for uniqueness
 	randomBytes := make([]byte, 16)
 	if _, err := rand.Read(randomBytes); err != nil {
@@ -24,17 +29,33 @@ func GenerateState(data map[string]string) (string, error) {
 	}
 	payloadPart := base64.RawURLEncoding.EncodeToString(payloadBytes)
 
-	// Final format: randomPart.payloadPart
-	return fmt.Sprintf("%s.%s", randomPart, payloadPart), nil
+	// Sign random and payload parts so the metadata (e.g. the user being
+	// linked) can't be tampered with on the way through the provider
+	signedPart := randomPart + "." + payloadPart
+
+	// Final format: randomPart.payloadPart.signature
+	return fmt.Sprintf("%s.%s", signedPart, h.signState(signedPart)), nil
+}
+
+// signState computes the HMAC signature of the state's random and payload parts
+func (h *handler) signState(value string) string {
+	mac := hmac.New(sha256.New, []byte(h.Config.JWTSecret))
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
 }
 
-// DecodeState decodes the metadata back from the state string
-func DecodeState(state string) (map[string]string, error) {
+// decodeState decodes the metadata back from the state string
+func (h *handler) decodeState(state string) (map[string]string, error) {
 	parts := strings.Split(state, ".")
-	if len(parts) != 2 {
+	if len(parts) != 3 {
 		return nil, fmt.Errorf("invalid state format")
 	}
 
+	expected := h.signState(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return nil, fmt.Errorf("invalid state signature")
+	}
+
 	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode state payload: %w", err)
@@ -47,3 +68,40 @@ func DecodeState(state string) (map[string]string, error) {
 
 	return data, nil
 }
+
+const oauthStateCookie = "oauth_state"
+
+// setStateCookie binds an OAuth state to the browser that started the flow,
+// so a callback can't be replayed in another user's browser.
+func (h *handler) setStateCookie(w http.ResponseWriter, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/api/v1/auth/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		Secure:   h.Config.IsProduction(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// checkStateCookie reports whether the state returned by the provider matches
+// the one stored in the browser, and clears the cookie.
+func (h *handler) checkStateCookie(w http.ResponseWriter, r *http.Request, state string) bool {
+	cookie, err := r.Cookie(oauthStateCookie)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     "/api/v1/auth/",
+		MaxAge:   -1,
+		Secure:   h.Config.IsProduction(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if err != nil || state == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) == 1
+}
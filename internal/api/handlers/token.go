@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/utils"
+)
+
+// maxAccessTokens bounds the personal access tokens of one account
+const maxAccessTokens = 50
+
+type CreateAccessTokenInput struct {
+	Name string `json:"name" validate:"required,max=100,printable"`
+	// Scopes granted to the token, e.g. "transfers:write" and "inbox:read"
+	Scopes []string `json:"scopes" validate:"required,max=10"`
+	// ExpiresInDays is the lifetime of the token; 0 creates a token that
+	// doesn't expire
+	ExpiresInDays int `json:"expiresInDays" validate:"min=0,max=365"`
+}
+
+// AccessTokenInfo describes a personal access token without its secret.
+type AccessTokenInfo struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func accessTokenInfo(t *models.AccessToken) AccessTokenInfo {
+	return AccessTokenInfo{
+		ID:         t.ID,
+		Name:       t.Name,
+		Hint:       t.Hint,
+		Scopes:     t.ScopeList(),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+// GET, POST /api/v1/me/tokens
+// AccessTokens godoc
+// @Summary List or create personal access tokens
+// @Description GET lists the personal access tokens of the signed in account. POST creates one; the token is only returned in this response. Clients send it as "Authorization: Bearer <token>" and may use the routes its scopes cover.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Param input body CreateAccessTokenInput false "Token to create (POST only)"
+// @Success 200 {object} utils.Payload{data=[]AccessTokenInfo} "Access tokens retrieved successfully"
+// @Success 201 {object} utils.Payload "Access token created successfully"
+// @Failure 400 {object} utils.Payload "Invalid input or too many tokens"
+// @Failure 401 {object} utils.Payload "Unauthorized"
+// @Failure 403 {object} utils.Payload "Requires a session"
+// @Failure 413 {object} utils.Payload "Request body too large"
+// @Router /api/v1/me/tokens [get]
+// @Router /api/v1/me/tokens [post]
+func (h *AccountHandler) AccessTokens(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listAccessTokens(w, r)
+	case http.MethodPost:
+		h.createAccessToken(w, r)
+	default:
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (h *AccountHandler) listAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		utils.ErrorResponse(w, utils.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	tokens, err := h.Repos.AccessTokens.ListByUser(r.Context(), userID)
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+
+	infos := make([]AccessTokenInfo, 0, len(tokens))
+	for i := range tokens {
+		infos = append(infos, accessTokenInfo(&tokens[i]))
+	}
+
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "Access tokens retrieved successfully",
+		Data:    infos,
+	})
+}
+
+func (h *AccountHandler) createAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		utils.ErrorResponse(w, utils.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var input CreateAccessTokenInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+
+	var scopes []string
+	for i, scope := range input.Scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			utils.ErrorResponse(w, utils.CodeInvalidInput, "Invalid input",
+				utils.FieldError{Field: fmt.Sprintf("scopes[%d]", i), Message: "is not a known scope"})
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	existing, err := h.Repos.AccessTokens.ListByUser(r.Context(), userID)
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+	if len(existing) >= maxAccessTokens {
+		utils.ErrorResponse(w, utils.CodeInvalidInput,
+			fmt.Sprintf("An account can have at most %d access tokens, revoke one first", maxAccessTokens))
+		return
+	}
+
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Failed to create token")
+		return
+	}
+	plain := models.AccessTokenPrefix + secret
+
+	token := models.AccessToken{
+		UserID:    userID,
+		Name:      input.Name,
+		Hint:      plain[len(plain)-4:],
+		TokenHash: utils.HashToken(plain),
+		Scopes:    strings.Join(scopes, " "),
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := h.Now().AddDate(0, 0, input.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := h.Repos.AccessTokens.Create(r.Context(), &token); err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Failed to create token")
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusCreated, utils.Payload{
+		Success: true,
+		Message: "Access token created successfully",
+		Data: map[string]any{
+			// Shown once, only its hash is stored
+			"token":       plain,
+			"accessToken": accessTokenInfo(&token),
+		},
+	})
+}
+
+// DELETE /api/v1/me/tokens/{id}
+// RevokeAccessToken godoc
+// @Summary Revoke a personal access token
+// @Description Deletes a personal access token of the signed in account. Requests using it fail from then on.
+// @Tags Account
+// @Produce json
+// @Param id path string true "Access token ID"
+// @Success 200 {object} utils.Payload "Access token revoked successfully"
+// @Failure 401 {object} utils.Payload "Unauthorized"
+// @Failure 403 {object} utils.Payload "Requires a session"
+// @Failure 404 {object} utils.Payload "Access token not found"
+// @Router /api/v1/me/tokens/{id} [delete]
+func (h *AccountHandler) RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, ok := currentUserID(r)
+	if !ok {
+		utils.ErrorResponse(w, utils.CodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeNotFound, "Access token not found")
+		return
+	}
+
+	err = h.Repos.AccessTokens.Delete(r.Context(), userID, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		utils.ErrorResponse(w, utils.CodeNotFound, "Access token not found")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(w, utils.CodeInternal, "Database error")
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "Access token revoked successfully",
+	})
+}
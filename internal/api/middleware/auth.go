@@ -2,69 +2,161 @@ package middleware
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/google/uuid"
+	"github.com/rohits-web03/obscyra/internal/logging"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/tokens"
 	"github.com/rohits-web03/obscyra/internal/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type contextKey string
 
-const UserIDKey contextKey = "userID"
+const (
+	UserIDKey contextKey = "userID"
+	// AccessTokenKey holds the *models.AccessToken of requests authenticated
+	// with a personal access token. It is absent for sessions.
+	AccessTokenKey contextKey = "accessToken"
+)
 
-var jwtSecret = config.Envs.JWTSecret
+// accessTokenTouchInterval limits how often the last use of an access token
+// is written back
+const accessTokenTouchInterval = time.Minute
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			next.ServeHTTP(w, r)
-			return
-		}
+var tracer = otel.Tracer("github.com/rohits-web03/obscyra/internal/api/middleware")
 
-		tokenStr, err := r.Cookie("token")
-		if err != nil {
-			utils.JSONResponse(w, http.StatusUnauthorized, utils.Payload{
-				Success: false,
-				Message: "Unauthorized",
-			})
-			return
-		}
+// AuthMiddleware rejects unauthenticated requests and puts the user's ID in
+// the request context. Browsers authenticate with the session cookie; other
+// clients send a session JWT or a personal access token as an
+// "Authorization: Bearer" header. now is the clock access tokens expire by.
+func AuthMiddleware(users repositories.UserRepository, accessTokens repositories.AccessTokenRepository, keys *tokens.Keys, now func() time.Time) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-		token, err := jwt.Parse(tokenStr.Value, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
+			ctx, span := tracer.Start(r.Context(), "AuthMiddleware")
+			var userID string
+			var accessToken *models.AccessToken
+			var ok bool
+			if header := r.Header.Get("Authorization"); header != "" {
+				userID, accessToken, ok = bearerUserID(ctx, header, users, accessTokens, keys, now())
+			} else if cookie, err := r.Cookie("token"); err == nil {
+				userID, ok = sessionUserID(ctx, cookie.Value, users, keys)
 			}
-			return []byte(jwtSecret), nil
+			span.End()
+
+			if !ok {
+				if r.Header.Get("Authorization") != "" {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				}
+				utils.ErrorResponse(w, utils.CodeUnauthorized, "Unauthorized")
+				return
+			}
+
+			if info := logging.RequestInfoFrom(r.Context()); info != nil {
+				info.UserID = userID
+			}
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("enduser.id", userID))
+
+			ctx = context.WithValue(r.Context(), UserIDKey, userID)
+			if accessToken != nil {
+				ctx = context.WithValue(ctx, AccessTokenKey, accessToken)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
+	}
+}
 
-		if err != nil || !token.Valid {
-			utils.JSONResponse(w, http.StatusUnauthorized, utils.Payload{
-				Success: false,
-				Message: "Unauthorized",
-			})
-			return
-		}
+// RequireScope rejects requests made with a personal access token that
+// wasn't granted scope. Sessions may use every route.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token, ok := r.Context().Value(AccessTokenKey).(*models.AccessToken); ok && !token.HasScope(scope) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+				utils.ErrorResponse(w, utils.CodeInsufficientScope, fmt.Sprintf("Access token lacks the %s scope", scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			utils.JSONResponse(w, http.StatusUnauthorized, utils.Payload{
-				Success: false,
-				Message: "Unauthorized",
-			})
+// RequireSession rejects requests made with a personal access token, for
+// routes managing the account itself.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(AccessTokenKey).(*models.AccessToken); ok {
+			utils.ErrorResponse(w, utils.CodeSessionRequired, "Access tokens can't be used for this route")
 			return
 		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		userID, ok := claims["userId"].(string)
-		if !ok || userID == "" {
-			utils.JSONResponse(w, http.StatusUnauthorized, utils.Payload{
-				Success: false,
-				Message: "Unauthorized",
-			})
-			return
+// bearerUserID authenticates an Authorization header holding either a
+// personal access token or a session JWT.
+func bearerUserID(ctx context.Context, header string, users repositories.UserRepository, accessTokens repositories.AccessTokenRepository, keys *tokens.Keys, now time.Time) (string, *models.AccessToken, bool) {
+	scheme, credential, _ := strings.Cut(header, " ")
+	credential = strings.TrimSpace(credential)
+	if !strings.EqualFold(scheme, "Bearer") || credential == "" {
+		return "", nil, false
+	}
+	if !strings.HasPrefix(credential, models.AccessTokenPrefix) {
+		userID, ok := sessionUserID(ctx, credential, users, keys)
+		return userID, nil, ok
+	}
+
+	token, err := accessTokens.GetByHash(ctx, utils.HashToken(credential))
+	if err != nil {
+		return "", nil, false
+	}
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return "", nil, false
+	}
+	// Tokens die with their account. Password resets and changes delete them.
+	if _, err := users.GetByID(ctx, token.UserID); err != nil {
+		return "", nil, false
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > accessTokenTouchInterval {
+		if err := accessTokens.MarkUsed(ctx, token.ID, now); err != nil {
+			slog.WarnContext(ctx, "Failed to record access token use", "error", err)
 		}
+	}
+	return token.UserID.String(), token, true
+}
 
-		ctx := context.WithValue(r.Context(), UserIDKey, userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// sessionUserID validates a session JWT and returns the ID of the user it
+// belongs to.
+func sessionUserID(ctx context.Context, tokenStr string, users repositories.UserRepository, keys *tokens.Keys) (string, bool) {
+	claims, err := keys.Verify(tokenStr)
+	if err != nil || claims.UserID == "" {
+		return "", false
+	}
+	id, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return "", false
+	}
+
+	// Sessions are revoked by bumping the user's session version, e.g.
+	// after a password change
+	user, err := users.GetByID(ctx, id)
+	if err != nil || user.SessionVersion != claims.SessionVersion {
+		return "", false
+	}
+
+	return claims.UserID, true
 }
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIPKey holds the client IP resolved by ClientAddress
+const clientIPKey contextKey = "clientIP"
+
+// TrustedProxies are the proxies whose X-Forwarded-For header is trusted
+// when resolving the client IP.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses a list of proxy IPs or CIDRs.
+func ParseTrustedProxies(proxies []string) (TrustedProxies, error) {
+	prefixes := make(TrustedProxies, 0, len(proxies))
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			addr, err := netip.ParseAddr(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func (t TrustedProxies) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range t {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client that sent r.
+// X-Forwarded-For is only honoured when the request comes from a trusted
+// proxy; it is then walked from the right, skipping trusted proxies, so a
+// client can't spoof its address by sending the header itself.
+func (t TrustedProxies) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !t.contains(remote) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	client := host
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			// Anything left of a malformed entry can't be trusted
+			break
+		}
+		client = addr.Unmap().String()
+		if !t.contains(addr) {
+			break
+		}
+	}
+	return client
+}
+
+// ClientAddress resolves the client IP of every request once, behind the
+// proxies, for ClientIP.
+func ClientAddress(proxies TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), clientIPKey, proxies.ClientIP(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientIP returns the client IP resolved by ClientAddress, or the peer
+// address when the request didn't pass through it.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+	return TrustedProxies(nil).ClientIP(r)
+}
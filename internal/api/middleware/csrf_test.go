@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	const secret = "test-secret"
+	token, err := NewCSRFToken(secret, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherUserToken, err := NewCSRFToken(secret, "user-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSecretToken, err := NewCSRFToken("other-secret", "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		userID        string
+		header        string
+		cookie        string
+		authorization string
+		wantAllowed   bool
+	}{
+		{name: "safe method", method: http.MethodGet, userID: "user-1", wantAllowed: true},
+		{name: "matching token", method: http.MethodPost, userID: "user-1", header: token, cookie: token, wantAllowed: true},
+		{name: "delete with matching token", method: http.MethodDelete, userID: "user-1", header: token, cookie: token, wantAllowed: true},
+		{name: "bearer authenticated", method: http.MethodPost, userID: "user-1", authorization: "Bearer x", wantAllowed: true},
+		{name: "no token", method: http.MethodPost, userID: "user-1"},
+		{name: "header only", method: http.MethodPost, userID: "user-1", header: token},
+		{name: "cookie only", method: http.MethodPost, userID: "user-1", cookie: token},
+		{name: "header and cookie differ", method: http.MethodPost, userID: "user-1", header: token, cookie: otherUserToken},
+		{name: "token of another user", method: http.MethodPost, userID: "user-1", header: otherUserToken, cookie: otherUserToken},
+		{name: "token signed with another secret", method: http.MethodPost, userID: "user-1", header: otherSecretToken, cookie: otherSecretToken},
+		{name: "unsigned token", method: http.MethodPost, userID: "user-1", header: "nonce", cookie: "nonce"},
+		{name: "no user", method: http.MethodPost, header: token, cookie: token},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := CSRF(secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+
+			r := httptest.NewRequest(tt.method, "/api/v1/files/complete", nil)
+			if tt.userID != "" {
+				r = r.WithContext(context.WithValue(r.Context(), UserIDKey, tt.userID))
+			}
+			if tt.header != "" {
+				r.Header.Set(CSRFHeader, tt.header)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: CSRFCookie, Value: tt.cookie})
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if called != tt.wantAllowed {
+				t.Errorf("request passed = %v, want %v", called, tt.wantAllowed)
+			}
+			if !tt.wantAllowed && w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
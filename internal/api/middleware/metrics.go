@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/logging"
+	"github.com/rohits-web03/obscyra/internal/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// unmatchedRoute labels requests that no route matched, so arbitrary paths
+// don't create new metric series.
+const unmatchedRoute = "unmatched"
+
+// Metrics records the count and latency of every request in m, labelled by
+// the route pattern set by Routes. It must run inside RequestID.
+func Metrics(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			rec, ok := w.(*statusRecorder)
+			if !ok {
+				rec = &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			}
+
+			next.ServeHTTP(rec, r)
+
+			route := unmatchedRoute
+			if info := logging.RequestInfoFrom(r.Context()); info != nil && info.Route != "" {
+				route = info.Route
+			}
+			m.ObserveRequest(metricMethod(r.Method), route, strconv.Itoa(rec.status), time.Since(start))
+		})
+	}
+}
+
+// Routes records the pattern mux matches for the request before serving it,
+// and names the request's trace span after it.
+// prefix is the path stripped before the request reached mux, so nested muxes
+// report the full pattern, e.g. /api/v1/share/{token}.
+func Routes(prefix string, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info := logging.RequestInfoFrom(r.Context()); info != nil {
+			_, pattern := mux.Handler(r)
+			switch method, path, found := strings.Cut(pattern, " "); {
+			case pattern == "":
+				info.Route = ""
+			case found:
+				// Patterns may start with a method, e.g. "GET /{token}"
+				info.Route = method + " " + prefix + path
+			default:
+				info.Route = prefix + pattern
+			}
+
+			if info.Route != "" {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + info.Route)
+				span.SetAttributes(attribute.String("http.route", info.Route))
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// metricMethod limits the method label to the standard methods.
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitPolicy is a token bucket holding Limit tokens that refills
+// completely over Period.
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token is available, when denied
+}
+
+// RateLimitStore keeps token buckets. Take must be atomic per key.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error)
+}
+
+// bucket is the persisted state of a token bucket
+type bucket struct {
+	Tokens     float64
+	RefilledAt time.Time
+}
+
+// take refills the bucket for the time elapsed since its last update and
+// tries to take one token from it.
+func (b *bucket) take(policy RateLimitPolicy, now time.Time) RateLimitResult {
+	limit := float64(policy.Limit)
+	rate := limit / policy.Period.Seconds() // tokens per second
+
+	if b.RefilledAt.IsZero() {
+		b.Tokens = limit
+	} else if elapsed := now.Sub(b.RefilledAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(limit, b.Tokens+elapsed*rate)
+	}
+	b.RefilledAt = now
+
+	res := RateLimitResult{}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.Tokens) / rate * float64(time.Second))
+	}
+	res.Remaining = int(b.Tokens)
+	res.Reset = time.Duration((limit - b.Tokens) / rate * float64(time.Second))
+	return res
+}
+
+// MemoryRateLimitStore keeps buckets in process memory, for single node deployments.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+// memoryBucket is a bucket with the time it will be full again under the
+// policy it was last taken from
+type memoryBucket struct {
+	bucket
+	fullAt time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Buckets that have refilled completely are dropped, a missing bucket
+	// starts out full
+	if now.Sub(s.lastSweep) > time.Minute {
+		s.lastSweep = now
+		for k, b := range s.buckets {
+			if !now.Before(b.fullAt) {
+				delete(s.buckets, k)
+			}
+		}
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{}
+		s.buckets[key] = b
+	}
+	res := b.take(policy, now)
+	b.fullAt = now.Add(res.Reset)
+	return res, nil
+}
+
+// PostgresRateLimitStore keeps buckets in the rate_limit_buckets table so
+// limits are shared between replicas.
+type PostgresRateLimitStore struct {
+	db *gorm.DB
+
+	mu         sync.Mutex
+	lastSweeps map[string]time.Time // by policy name
+}
+
+func NewPostgresRateLimitStore(db *gorm.DB) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{db: db, lastSweeps: make(map[string]time.Time)}
+}
+
+func (s *PostgresRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error) {
+	if err := s.sweep(ctx, policy, now); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	var res RateLimitResult
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.RateLimitBucket{Key: key, Tokens: float64(policy.Limit), RefilledAt: now}).Error; err != nil {
+			return err
+		}
+
+		var row models.RateLimitBucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+
+		b := bucket{Tokens: row.Tokens, RefilledAt: row.RefilledAt}
+		res = b.take(policy, now)
+
+		return tx.Model(&row).Updates(map[string]any{
+			"tokens":      b.Tokens,
+			"refilled_at": b.RefilledAt,
+		}).Error
+	})
+	return res, err
+}
+
+// sweep deletes the buckets of policy that have refilled completely, so the
+// table doesn't grow with every client ever seen. A bucket untouched for a
+// whole period is full whatever it held. Each replica sweeps a policy at
+// most once a minute.
+func (s *PostgresRateLimitStore) sweep(ctx context.Context, policy RateLimitPolicy, now time.Time) error {
+	s.mu.Lock()
+	if now.Sub(s.lastSweeps[policy.Name]) <= time.Minute {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastSweeps[policy.Name] = now
+	s.mu.Unlock()
+
+	return s.db.WithContext(ctx).
+		Where("key LIKE ? AND refilled_at < ?", policy.Name+":%", now.Add(-policy.Period)).
+		Delete(&models.RateLimitBucket{}).Error
+}
+
+// NewRateLimitStore returns the bucket store configured in cfg. The memory
+// store suits a single node; the postgres store shares buckets across
+// replicas.
+func NewRateLimitStore(cfg config.RateLimitConfig, db *gorm.DB, logger *slog.Logger) (RateLimitStore, error) {
+	var store RateLimitStore
+	switch cfg.Store {
+	case "", "memory":
+		store = NewMemoryRateLimitStore()
+	case "postgres":
+		store = NewPostgresRateLimitStore(db)
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", cfg.Store)
+	}
+
+	logger.Info("Rate limiting initialized", "store", cfg.Store)
+	return store, nil
+}
+
+// RateLimit limits requests with a token bucket per client, kept in store.
+// Requests are keyed by the authenticated user ID when AuthMiddleware ran
+// before, and by client IP otherwise. A policy with a zero limit disables
+// limiting.
+func RateLimit(store RateLimitStore, policy RateLimitPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if policy.Limit <= 0 || policy.Period <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := "ip:" + ClientIP(r)
+			if userID, ok := r.Context().Value(UserIDKey).(string); ok && userID != "" {
+				key = "user:" + userID
+			}
+
+			res, err := store.Take(r.Context(), policy.Name+":"+key, policy, time.Now())
+			if err != nil {
+				// Fail open: a broken store shouldn't take the API down
+				slog.ErrorContext(r.Context(), "Rate limit store error", "policy", policy.Name, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Period.Seconds())))
+			h.Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				utils.ErrorResponse(w, utils.CodeRateLimited, "Too many requests")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
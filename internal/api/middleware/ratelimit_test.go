@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type take struct {
+		after     time.Duration // since t0
+		allowed   bool
+		remaining int
+	}
+	tests := []struct {
+		name   string
+		policy RateLimitPolicy
+		takes  []take
+	}{
+		{
+			name:   "denies once empty",
+			policy: RateLimitPolicy{Name: "test", Limit: 2, Period: time.Minute},
+			takes: []take{
+				{0, true, 1},
+				{0, true, 0},
+				{0, false, 0},
+			},
+		},
+		{
+			name:   "refills gradually",
+			policy: RateLimitPolicy{Name: "test", Limit: 2, Period: time.Minute},
+			takes: []take{
+				{0, true, 1},
+				{0, true, 0},
+				{29 * time.Second, false, 0},
+				{30 * time.Second, true, 0},
+			},
+		},
+		{
+			name:   "never holds more than the limit",
+			policy: RateLimitPolicy{Name: "test", Limit: 2, Period: time.Minute},
+			takes: []take{
+				{0, true, 1},
+				{time.Hour, true, 1},
+			},
+		},
+		{
+			// A bucket idle for longer than the sweep interval but not yet
+			// full must be kept
+			name:   "keeps buckets of long periods until full",
+			policy: RateLimitPolicy{Name: "test", Limit: 2, Period: 4 * time.Hour},
+			takes: []take{
+				{0, true, 1},
+				{0, true, 0},
+				{3 * time.Hour, true, 0},
+				{5 * time.Hour, true, 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryRateLimitStore()
+			for i, tk := range tt.takes {
+				res, err := store.Take(context.Background(), "key", tt.policy, t0.Add(tk.after))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Allowed != tk.allowed || res.Remaining != tk.remaining {
+					t.Errorf("take %d: allowed=%v remaining=%d, want allowed=%v remaining=%d",
+						i, res.Allowed, res.Remaining, tk.allowed, tk.remaining)
+				}
+				if !res.Allowed && res.RetryAfter <= 0 {
+					t.Errorf("take %d: denied without RetryAfter", i)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreDropsFullBuckets(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	policy := RateLimitPolicy{Name: "test", Limit: 1, Period: time.Minute}
+	store := NewMemoryRateLimitStore()
+
+	for _, key := range []string{"a", "b"} {
+		if _, err := store.Take(context.Background(), key, policy, t0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Take(context.Background(), "c", policy, t0.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.buckets) != 1 {
+		t.Errorf("%d buckets kept, want only the one just taken from", len(store.buckets))
+	}
+}
@@ -1,70 +1,221 @@
 package api
 
 import (
+	"crypto/subtle"
 	"fmt"
-	"log"
 	"net/http"
+	"time"
 
 	_ "github.com/rohits-web03/obscyra/docs"
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	"github.com/rohits-web03/obscyra/internal/api/handlers"
 	"github.com/rohits-web03/obscyra/internal/api/middleware"
+	"github.com/rohits-web03/obscyra/internal/api/services"
+	"github.com/rohits-web03/obscyra/internal/app"
 	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/rohits-web03/obscyra/internal/health"
+	"github.com/rohits-web03/obscyra/internal/metrics"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/repositories"
 	"github.com/rs/cors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-func SetupRouter() http.Handler {
+// SetupRouter builds the HTTP handler serving the API, with handlers bound
+// to the dependencies in a.
+func SetupRouter(a *app.App) http.Handler {
 	mainMux := http.NewServeMux()
-	c := cors.New(config.Envs.CorsConfig)
+	c := cors.New(a.Config.CorsOptions())
 
-	// ---------- PUBLIC ROUTES ----------
-	mainMux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	authHandler := handlers.NewAuthHandler(a)
+	accountHandler := handlers.NewAccountHandler(a)
+	transfers := services.NewTransferService(a.Repos, a.Storage, a.Metrics, a.Config.Transfers, a.Now)
+	fileHandler := handlers.NewFileHandler(a, transfers)
+	shareHandler := handlers.NewShareHandler(a, transfers)
+
+	// ---------- RATE LIMITS ----------
+	limits := a.Config.RateLimit
+	globalLimit := middleware.RateLimit(a.RateLimits, rateLimitPolicy("global", limits.Global))
+	authLimit := middleware.RateLimit(a.RateLimits, rateLimitPolicy("auth", limits.Auth))
+	presignLimit := middleware.RateLimit(a.RateLimits, rateLimitPolicy("presign", limits.Presign))
+	shareLimit := middleware.RateLimit(a.RateLimits, rateLimitPolicy("share", limits.Share))
+
+	// ---------- SECURITY HEADERS ----------
+	apiHeaders := middleware.SecurityHeadersPolicy{
+		NoSniff:        true,
+		ReferrerPolicy: "no-referrer",
+		// Responses are data, never documents to render or embed
+		ContentSecurityPolicy:     "default-src 'none'; frame-ancestors 'none'",
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginResourcePolicy: "same-origin",
+	}
+	if a.Config.IsProduction() {
+		apiHeaders.HSTSMaxAge = 2 * 365 * 24 * time.Hour
+	}
+	// Responses of authenticated routes and sign in belong to one user
+	sessionHeaders := apiHeaders
+	sessionHeaders.NoStoreJSON = true
+	// Swagger UI loads its assets from /docs/ and configures itself in an
+	// inline script
+	docsHeaders := apiHeaders
+	docsHeaders.ContentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; " +
+		"style-src 'self' 'unsafe-inline'; img-src 'self' data:; " +
+		"frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+	// Presigned URLs are fetched by the frontend like the URLs of R2
+	storageHeaders := apiHeaders
+	storageHeaders.CrossOriginResourcePolicy = "cross-origin"
+
+	// ---------- PROBES ----------
+	// Kubelet probes and Prometheus scrapes come from a few fixed addresses,
+	// so they are kept out of the global per-IP limit: a 429 on /livez would
+	// restart a healthy pod.
+	rootMux := http.NewServeMux()
+	rootMux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, "OK")
 	})
 
-	mainMux.HandleFunc("/docs/", httpSwagger.WrapHandler)
+	rootMux.HandleFunc("/livez", health.Livez)
+	rootMux.HandleFunc("/readyz", a.Health.Readyz)
+
+	rootMux.Handle("/metrics", metricsHandler(a.Metrics, a.Config.MetricsToken))
+
+	// ---------- PUBLIC ROUTES ----------
+	mainMux.Handle("/docs/", middleware.SecurityHeaders(docsHeaders)(httpSwagger.WrapHandler))
+
+	mainMux.HandleFunc("/.well-known/jwks.json", authHandler.JWKS)
+
+	// Presigned URLs of the local store point back at the API
+	if local, ok := a.Storage.(*repositories.LocalStorage); ok {
+		storageMux := http.NewServeMux()
+		storageMux.Handle("/{key...}", local)
+		mainMux.Handle(repositories.LocalStoragePath+"/",
+			middleware.SecurityHeaders(storageHeaders)(
+				http.StripPrefix(repositories.LocalStoragePath, middleware.Routes(repositories.LocalStoragePath, storageMux)),
+			),
+		)
+	}
 
 	authMux := http.NewServeMux()
-	authMux.HandleFunc("/sign-up", handlers.RegisterUser)
-	authMux.HandleFunc("/login", handlers.LoginUser)
-	authMux.HandleFunc("/google/login", handlers.HandleGoogleLogin)
-	authMux.HandleFunc("/google/callback", handlers.HandleGoogleCallback)
+	authMux.Handle("/sign-up", authLimit(http.HandlerFunc(authHandler.RegisterUser)))
+	authMux.Handle("/login", authLimit(http.HandlerFunc(authHandler.LoginUser)))
+	authMux.HandleFunc("/verify-email", accountHandler.VerifyEmail)
+	authMux.Handle("/verify-email/resend", authLimit(http.HandlerFunc(accountHandler.ResendVerificationEmail)))
+	authMux.Handle("/password/forgot", authLimit(http.HandlerFunc(accountHandler.ForgotPassword)))
+	authMux.Handle("/password/reset", authLimit(http.HandlerFunc(accountHandler.ResetPassword)))
+	authMux.HandleFunc("/google/login", authHandler.HandleGoogleLogin)
+	authMux.HandleFunc("/google/callback", authHandler.HandleGoogleCallback)
+	authMux.HandleFunc("/oidc/{provider}/login", authHandler.HandleOIDCLogin)
+	authMux.HandleFunc("/oidc/{provider}/callback", authHandler.HandleOIDCCallback)
 
 	mainMux.Handle("/api/v1/auth/",
-		http.StripPrefix("/api/v1/auth", authMux),
+		middleware.SecurityHeaders(sessionHeaders)(
+			http.StripPrefix("/api/v1/auth", middleware.Routes("/api/v1/auth", authMux)),
+		),
 	)
 
 	// ---------- PROTECTED ROUTES ----------
+	requireAuth := middleware.AuthMiddleware(a.Repos.Users, a.Repos.AccessTokens, a.Tokens, a.Now)
+	// Cookie authenticated requests changing state must prove they come
+	// from the frontend
+	csrf := middleware.CSRF(a.Config.JWTSecret)
 	protectedMux := http.NewServeMux()
 
 	fileMux := http.NewServeMux()
-	fileMux.HandleFunc("/presign", handlers.PresignUpload)
-	fileMux.HandleFunc("/complete", handlers.CompleteUpload)
+	fileMux.Handle("/presign", presignLimit(http.HandlerFunc(fileHandler.PresignUpload)))
+	fileMux.HandleFunc("/complete", fileHandler.CompleteUpload)
 
 	shareMux := http.NewServeMux()
-	shareMux.HandleFunc("/{token}", handlers.GetSharedFiles)
-	shareMux.HandleFunc("/{token}/presign-download/{index}", handlers.PresignDownload)
+	shareMux.Handle("/{token}", shareLimit(http.HandlerFunc(shareHandler.GetSharedFiles)))
+	shareMux.Handle("/{token}/presign-download/{index}", shareLimit(http.HandlerFunc(shareHandler.PresignDownload)))
 
+	meMux := http.NewServeMux()
+	meMux.HandleFunc("/password", accountHandler.ChangePassword)
+	meMux.HandleFunc("/identities", accountHandler.ListIdentities)
+	meMux.HandleFunc("/identities/{provider}", accountHandler.UnlinkIdentity)
+	meMux.HandleFunc("/identities/{provider}/link", accountHandler.LinkIdentity)
+	meMux.HandleFunc("/tokens", accountHandler.AccessTokens)
+	meMux.HandleFunc("/tokens/{id}", accountHandler.RevokeAccessToken)
+
+	// Access tokens are limited to the routes their scopes cover; account
+	// management needs a session
 	protectedMux.Handle("/files/",
-		http.StripPrefix("/files", fileMux),
+		middleware.RequireScope(models.ScopeTransfersWrite)(
+			http.StripPrefix("/files", middleware.Routes("/api/v1/files", fileMux)),
+		),
 	)
 	protectedMux.Handle("/share/",
-		http.StripPrefix("/share", shareMux),
+		middleware.RequireScope(models.ScopeInboxRead)(
+			http.StripPrefix("/share", middleware.Routes("/api/v1/share", shareMux)),
+		),
+	)
+	protectedMux.Handle("/me/",
+		middleware.RequireSession(
+			http.StripPrefix("/me", middleware.Routes("/api/v1/me", meMux)),
+		),
 	)
 
-	protectedMux.HandleFunc("/logout", handlers.Logout)
+	protectedMux.Handle("/logout", middleware.RequireSession(http.HandlerFunc(authHandler.Logout)))
+	protectedMux.Handle("/csrf-token", middleware.RequireSession(http.HandlerFunc(authHandler.CSRFToken)))
 
 	mainMux.Handle("/api/v1/",
 		http.StripPrefix(
 			"/api/v1",
-			middleware.AuthMiddleware(protectedMux),
+			middleware.SecurityHeaders(sessionHeaders)(
+				requireAuth(csrf(middleware.Routes("/api/v1", protectedMux))),
+			),
 		),
 	)
 
-	log.Println("Router initialized")
-	handler := c.Handler(mainMux)
+	a.Logger.Info("Router initialized")
+	rootMux.Handle("/", globalLimit(middleware.Routes("", mainMux)))
+	handler := middleware.Routes("", rootMux)
+	// Defaults for routes outside the groups above, including errors
+	handler = middleware.SecurityHeaders(apiHeaders)(handler)
+	handler = c.Handler(handler)
+	handler = middleware.Metrics(a.Metrics)(handler)
 	handler = middleware.Logger(handler)
+	// Resolves the client IP behind the trusted proxies for everything above
+	handler = middleware.ClientAddress(a.TrustedProxies)(handler)
+	handler = middleware.RequestID(handler)
+	// Outermost so the server span covers the whole request, and the trace ID
+	// is in the context for every log line
+	handler = otelhttp.NewHandler(handler, "http.server",
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			switch r.URL.Path {
+			case "/metrics", "/health", "/livez", "/readyz":
+				return false
+			}
+			return true
+		}),
+	)
 	return handler
-}
\ No newline at end of file
+}
+
+// rateLimitPolicy builds a named middleware policy from a configured rule
+func rateLimitPolicy(name string, rule config.RateLimitRule) middleware.RateLimitPolicy {
+	return middleware.RateLimitPolicy{
+		Name:   name,
+		Limit:  rule.Limit,
+		Period: rule.Period,
+	}
+}
+
+// metricsHandler serves the Prometheus metrics, requiring the token as a
+// bearer token when one is configured
+func metricsHandler(m *metrics.Metrics, token string) http.Handler {
+	h := m.Handler()
+	if token == "" {
+		return h
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
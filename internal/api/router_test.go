@@ -0,0 +1,67 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/api/middleware"
+	"github.com/rohits-web03/obscyra/internal/api/services"
+	"github.com/rohits-web03/obscyra/internal/app"
+	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/rohits-web03/obscyra/internal/health"
+	"github.com/rohits-web03/obscyra/internal/metrics"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/tokens"
+)
+
+// TestGlobalLimitSparesProbes exhausts the global limit of one client and
+// checks probes and scrapes from the same address still get through.
+func TestGlobalLimitSparesProbes(t *testing.T) {
+	cfg := config.Default()
+	cfg.JWTSecret = "test-secret"
+	cfg.RateLimit.Global = config.RateLimitRule{Limit: 1, Period: time.Hour}
+
+	storage, err := repositories.NewLocalStorage(t.TempDir(), cfg.BaseURL, cfg.JWTSecret, cfg.Transfers.MaxUploadSize, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := tokens.New(cfg.JWT, cfg.JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := SetupRouter(&app.App{
+		Config:     cfg,
+		Repos:      repositories.NewMemoryRepositories(),
+		Storage:    storage,
+		Tokens:     keys,
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Providers:  services.NewIdentityProviders(cfg.Google, nil),
+		RateLimits: middleware.NewMemoryRateLimitStore(),
+		Metrics:    metrics.New(),
+		Health:     health.New(),
+	})
+
+	get := func(path string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	if code := get("/.well-known/jwks.json"); code != http.StatusOK {
+		t.Fatalf("first request returned %d", code)
+	}
+	if code := get("/.well-known/jwks.json"); code != http.StatusTooManyRequests {
+		t.Fatalf("second request returned %d, want %d", code, http.StatusTooManyRequests)
+	}
+	for _, path := range []string{"/health", "/livez", "/readyz", "/metrics"} {
+		for range 3 {
+			if code := get(path); code != http.StatusOK {
+				t.Errorf("%s returned %d, want %d", path, code, http.StatusOK)
+			}
+		}
+	}
+}
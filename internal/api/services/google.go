@@ -1,20 +1,21 @@
 package services
 
 import (
-    "golang.org/x/oauth2"
-    "golang.org/x/oauth2/google"
-    "os"
+	"github.com/rohits-web03/obscyra/internal/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
 )
 
-var GoogleOauthConfig = &oauth2.Config{
-    ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-    ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-    RedirectURL:  "http://localhost:8080/api/v1/auth/google/callback",
-    Scopes: []string{
-        "https://www.googleapis.com/auth/userinfo.email",
-        "https://www.googleapis.com/auth/userinfo.profile",
-    },
-    Endpoint: google.Endpoint,
+// NewGoogleOAuthConfig returns the OAuth client for Google sign-in.
+func NewGoogleOAuthConfig(cfg config.GoogleConfig) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes: []string{
+			"https://www.googleapis.com/auth/userinfo.email",
+			"https://www.googleapis.com/auth/userinfo.profile",
+		},
+		Endpoint: google.Endpoint,
+	}
 }
-
-var OauthStateString = "randomlySelectedString"
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/rohits-web03/obscyra/internal/config"
+	"golang.org/x/oauth2"
+)
+
+// OIDCProvider is a generic OpenID Connect identity provider. The discovery
+// document is fetched lazily on first use so an unreachable IdP does not
+// prevent the server from starting, and is retried until it succeeds.
+type OIDCProvider struct {
+	Name string
+	cfg  config.OIDCProviderConfig
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// IdentityProviders are the external providers users can sign in with:
+// Google and the configured OIDC providers.
+type IdentityProviders struct {
+	Google *oauth2.Config
+	oidc   map[string]*OIDCProvider
+}
+
+// NewIdentityProviders registers the configured providers. They are
+// validated with the rest of the configuration by config.Validate.
+func NewIdentityProviders(google config.GoogleConfig, providers []config.OIDCProviderConfig) *IdentityProviders {
+	registry := make(map[string]*OIDCProvider, len(providers))
+	for _, p := range providers {
+		registry[p.Name] = &OIDCProvider{Name: p.Name, cfg: p}
+		slog.Info("Registered OIDC provider", "provider", p.Name, "issuer", p.IssuerURL)
+	}
+	return &IdentityProviders{Google: NewGoogleOAuthConfig(google), oidc: registry}
+}
+
+// OIDC looks up a registered OIDC provider by name.
+func (p *IdentityProviders) OIDC(name string) (*OIDCProvider, bool) {
+	provider, ok := p.oidc[name]
+	return provider, ok
+}
+
+// Discover resolves the provider endpoints and signing keys from the issuer's
+// discovery document and returns the OAuth2 config and ID token verifier.
+func (p *OIDCProvider) Discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth2 != nil {
+		return p.oauth2, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.cfg.IssuerURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc discovery for %q failed: %w", p.Name, err)
+	}
+
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint:     provider.Endpoint(),
+	}
+	// The verifier checks the signature against the provider's JWKS as well
+	// as the issuer, audience and expiry claims
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+
+	return p.oauth2, p.verifier, nil
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/rohits-web03/obscyra/internal/metrics"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/utils"
+	"golang.org/x/sync/errgroup"
+)
+
+// MaxTransferFiles is the most files one transfer can hold.
+const MaxTransferFiles = 100
+
+// Transfer errors. Callers match them with errors.Is; some are wrapped with
+// details such as the offending filename.
+var (
+	ErrEmptyTransfer       = errors.New("missing token or no files provided")
+	ErrTransferTooLarge    = errors.New("total size exceeds the upload limit")
+	ErrTooManyFiles        = fmt.Errorf("a transfer can hold at most %d files", MaxTransferFiles)
+	ErrFileNotUploaded     = errors.New("file not found")
+	ErrInvalidFileKey      = errors.New("file key does not belong to this upload session")
+	ErrRecipientNotFound   = errors.New("recipient not found for provided public key")
+	ErrRecipientUnverified = errors.New("recipient has not verified their email address")
+	ErrTransferNotFound    = errors.New("invalid or expired share link")
+	ErrTransferExpired     = errors.New("this link has expired")
+	ErrNotRecipient        = errors.New("not an authorized recipient for this transfer")
+	ErrRecipientKeysReset  = errors.New("transfer was encrypted for a key that has since been reset")
+	ErrFileNotFound        = errors.New("file not found in transfer")
+)
+
+// UploadFile is a file the sender intends to upload.
+type UploadFile struct {
+	Filename string
+	Size     int64
+}
+
+// PresignedUpload is where a single file of an upload session goes.
+type PresignedUpload struct {
+	Filename  string
+	UploadURL string
+	Key       string
+}
+
+// UploadSession groups the presigned uploads of one transfer under its share
+// token.
+type UploadSession struct {
+	Token   string
+	Uploads []PresignedUpload
+}
+
+// UploadedFile describes a file the sender reports as uploaded.
+type UploadedFile struct {
+	Filename    string
+	Size        int64 // declared by the sender; the stored size is used instead
+	Key         string
+	ContentType string
+}
+
+// RecipientKey is the transfer key encrypted for the owner of PublicKey.
+type RecipientKey struct {
+	PublicKey    string
+	EncryptedKey string
+}
+
+// FinalizeInput is what FinalizeTransfer needs to register a transfer.
+type FinalizeInput struct {
+	Token string
+	// SenderID is nil for anonymous transfers
+	SenderID   *uuid.UUID
+	Files      []UploadedFile
+	Recipients []RecipientKey
+}
+
+// Download is a presigned URL for one file of a transfer.
+type Download struct {
+	URL  string
+	File models.File
+	// Filename is the sanitized name the file downloads as
+	Filename string
+}
+
+// TransferService implements the upload and download flows independently of
+// HTTP, so they can be reused by other entry points such as a CLI or
+// background jobs.
+type TransferService struct {
+	repos   repositories.Repositories
+	storage repositories.Storage
+	metrics *metrics.Metrics
+	cfg     config.TransferConfig
+	now     func() time.Time
+}
+
+func NewTransferService(repos repositories.Repositories, storage repositories.Storage, m *metrics.Metrics, cfg config.TransferConfig, now func() time.Time) *TransferService {
+	return &TransferService{repos: repos, storage: storage, metrics: m, cfg: cfg, now: now}
+}
+
+// MaxUploadSize is the largest total size of a transfer in bytes.
+func (s *TransferService) MaxUploadSize() int64 {
+	return s.cfg.MaxUploadSize
+}
+
+// TTL is how long a transfer can be downloaded after it is finalized.
+func (s *TransferService) TTL() time.Duration {
+	return s.cfg.TTL
+}
+
+// CreateUploadSession generates a share token and a presigned upload URL for
+// every file.
+func (s *TransferService) CreateUploadSession(ctx context.Context, files []UploadFile) (*UploadSession, error) {
+	if len(files) == 0 {
+		return nil, ErrEmptyTransfer
+	}
+	if len(files) > MaxTransferFiles {
+		return nil, ErrTooManyFiles
+	}
+
+	var totalSize int64
+	for _, f := range files {
+		totalSize += f.Size
+	}
+	if totalSize > s.cfg.MaxUploadSize {
+		return nil, ErrTransferTooLarge
+	}
+
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("creating transfer token: %w", err)
+	}
+
+	session := &UploadSession{
+		Token:   token,
+		Uploads: make([]PresignedUpload, 0, len(files)),
+	}
+	for _, f := range files {
+		filename := utils.SanitizeFilename(f.Filename)
+		// Keys never contain client input; the filename is kept in the
+		// database and applied when the file is downloaded
+		key := uploadKeyPrefix(token) + uuid.New().String()
+		uploadURL, err := s.storage.GeneratePresignedPutURL(ctx, key, s.cfg.PresignExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("presigning upload of %s: %w", filename, err)
+		}
+		session.Uploads = append(session.Uploads, PresignedUpload{
+			Filename:  filename,
+			UploadURL: uploadURL,
+			Key:       key,
+		})
+	}
+	s.metrics.ObserveUploadPresigns(len(session.Uploads))
+
+	return session, nil
+}
+
+// FinalizeTransfer checks that every file was uploaded and registers the
+// transfer, its files and its recipients. Recipients are resolved by public
+// key and must have verified their email; otherwise nothing is stored.
+func (s *TransferService) FinalizeTransfer(ctx context.Context, input FinalizeInput) (*models.Transfer, error) {
+	if input.Token == "" || len(input.Files) == 0 {
+		return nil, ErrEmptyTransfer
+	}
+	if len(input.Files) > MaxTransferFiles {
+		return nil, ErrTooManyFiles
+	}
+
+	// Only objects presigned for this session may be claimed, each once
+	seen := make(map[string]bool, len(input.Files))
+	for _, f := range input.Files {
+		if !isUploadKey(input.Token, f.Key) || seen[f.Key] {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFileKey, f.Key)
+		}
+		seen[f.Key] = true
+	}
+
+	// The sizes declared by the client are not trusted, the quota applies to
+	// what was actually uploaded
+	sizes, err := s.uploadedSizes(ctx, input.Files)
+	if err != nil {
+		return nil, err
+	}
+	var totalSize int64
+	for _, size := range sizes {
+		totalSize += size
+	}
+	if totalSize > s.cfg.MaxUploadSize {
+		return nil, ErrTransferTooLarge
+	}
+
+	transfer := models.Transfer{
+		Token:       input.Token,
+		ExpiresAt:   s.now().Add(s.cfg.TTL),
+		IsAnonymous: input.SenderID == nil,
+		SenderID:    input.SenderID,
+		TotalSize:   totalSize,
+	}
+
+	for i, f := range input.Files {
+		transfer.Files = append(transfer.Files, models.File{
+			Filename:    utils.SanitizeFilename(f.Filename),
+			Size:        sizes[i],
+			Path:        f.Key,
+			ContentType: f.ContentType,
+			Index:       i,
+		})
+	}
+
+	for _, rKey := range input.Recipients {
+		user, err := s.repos.Users.GetByPublicKey(ctx, rKey.PublicKey)
+		if errors.Is(err, repositories.ErrNotFound) {
+			// Fail the whole transfer rather than send it partially
+			return nil, ErrRecipientNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Only accounts with a verified email can receive transfers. Accounts
+		// from before verification existed are verified by the migration.
+		if !user.EmailVerified {
+			return nil, ErrRecipientUnverified
+		}
+
+		transfer.Recipients = append(transfer.Recipients, models.Recipient{
+			ReceiverID:   user.ID,
+			EncryptedKey: rKey.EncryptedKey,
+		})
+	}
+
+	if err := s.repos.Transfers.Create(ctx, &transfer); err != nil {
+		return nil, err
+	}
+
+	s.metrics.ObserveUploadCompletion(totalSize)
+
+	return &transfer, nil
+}
+
+// uploadKeyPrefix is the storage prefix of the files uploaded under token
+func uploadKeyPrefix(token string) string {
+	return "uploads/" + token + "/"
+}
+
+// isUploadKey reports whether key is one CreateUploadSession issues for token
+func isUploadKey(token, key string) bool {
+	id, ok := strings.CutPrefix(key, uploadKeyPrefix(token))
+	if !ok {
+		return false
+	}
+	parsed, err := uuid.Parse(id)
+	return err == nil && parsed.String() == id
+}
+
+// uploadedSizes checks concurrently that every file exists in storage and
+// returns their stored sizes, in the order of files.
+func (s *TransferService) uploadedSizes(ctx context.Context, files []UploadedFile) ([]int64, error) {
+	sizes := make([]int64, len(files))
+	g, ctx := errgroup.WithContext(ctx)
+	for i, f := range files {
+		g.Go(func() error {
+			size, err := s.storage.ObjectSize(ctx, f.Key)
+			if errors.Is(err, repositories.ErrNotFound) {
+				return fmt.Errorf("%w: %s", ErrFileNotUploaded, f.Filename)
+			}
+			if err != nil {
+				return fmt.Errorf("verifying %s: %w", f.Filename, err)
+			}
+			sizes[i] = size
+			return nil
+		})
+	}
+	return sizes, g.Wait()
+}
+
+// AuthorizeRecipient returns the transfer shared under token, with its files,
+// and the envelope holding its key for receiverID. It fails if the transfer
+// expired, receiverID isn't a recipient or can no longer decrypt it.
+func (s *TransferService) AuthorizeRecipient(ctx context.Context, token string, receiverID uuid.UUID) (*models.Transfer, *models.Recipient, error) {
+	transfer, err := s.repos.Transfers.GetByToken(ctx, token)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil, ErrTransferNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.now().After(transfer.ExpiresAt) {
+		return nil, nil, ErrTransferExpired
+	}
+
+	// Digital envelope check
+	recipient, err := s.repos.Recipients.Get(ctx, transfer.ID, receiverID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil, ErrNotRecipient
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The recipient's key pair was replaced by a password reset after this
+	// transfer was sent, so its envelope can no longer be opened
+	if receiver, err := s.repos.Users.GetByID(ctx, receiverID); err == nil &&
+		receiver.KeysResetAt != nil && recipient.CreatedAt.Before(*receiver.KeysResetAt) {
+		return nil, nil, ErrRecipientKeysReset
+	}
+
+	return transfer, recipient, nil
+}
+
+// IssueDownload returns a presigned download URL for the file at index in
+// the transfer shared under token, if receiverID may download it.
+func (s *TransferService) IssueDownload(ctx context.Context, token string, receiverID uuid.UUID, index int) (*Download, error) {
+	transfer, _, err := s.AuthorizeRecipient(ctx, token, receiverID)
+	if err != nil {
+		return nil, err
+	}
+
+	var file *models.File
+	for i := range transfer.Files {
+		if transfer.Files[i].Index == index {
+			file = &transfer.Files[i]
+			break
+		}
+	}
+	if file == nil {
+		return nil, ErrFileNotFound
+	}
+
+	// Transfers stored before filenames were sanitized still hold the raw name
+	filename := utils.SanitizeFilename(file.Filename)
+	url, err := s.storage.GeneratePresignedGetURL(ctx, file.Path, filename, s.cfg.PresignExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("presigning download of %s: %w", filename, err)
+	}
+	s.metrics.ObserveDownloadPresign()
+
+	return &Download{URL: url, File: *file, Filename: filename}, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+)
+
+// TestFinalizeTransferQuota uploads more than the sender declared and checks
+// the quota and the stored sizes follow what is in storage.
+func TestFinalizeTransferQuota(t *testing.T) {
+	const maxUploadSize = 10
+
+	tests := []struct {
+		name     string
+		uploaded []string // contents of the uploaded files, declared as 1 byte each
+		wantErr  error
+	}{
+		{name: "within the quota", uploaded: []string{"abc", "defg"}},
+		{name: "declared small, uploaded large", uploaded: []string{"abcdef", "ghijkl"}, wantErr: ErrTransferTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage, err := repositories.NewLocalStorage(t.TempDir(), "http://localhost:8080", "secret", 1<<20, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			transfers := NewTransferService(repositories.NewMemoryRepositories(), storage, nil,
+				config.TransferConfig{MaxUploadSize: maxUploadSize, TTL: time.Hour, PresignExpiry: time.Minute}, time.Now)
+
+			declared := make([]UploadFile, len(tt.uploaded))
+			for i := range declared {
+				declared[i] = UploadFile{Filename: "file.txt", Size: 1}
+			}
+			session, err := transfers.CreateUploadSession(ctx, declared)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var files []UploadedFile
+			for i, upload := range session.Uploads {
+				u, err := url.Parse(upload.UploadURL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r := httptest.NewRequest(http.MethodPut, strings.TrimPrefix(u.Path, repositories.LocalStoragePath)+"?"+u.RawQuery, strings.NewReader(tt.uploaded[i]))
+				w := httptest.NewRecorder()
+				storage.ServeHTTP(w, r)
+				if w.Code != http.StatusOK {
+					t.Fatalf("upload returned %d %s", w.Code, w.Body)
+				}
+				files = append(files, UploadedFile{Filename: upload.Filename, Size: 1, Key: upload.Key, ContentType: "text/plain"})
+			}
+
+			transfer, err := transfers.FinalizeTransfer(ctx, FinalizeInput{Token: session.Token, Files: files})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("FinalizeTransfer returned %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var total int64
+			for i, f := range transfer.Files {
+				if f.Size != int64(len(tt.uploaded[i])) {
+					t.Errorf("file %d size = %d, want %d", i, f.Size, len(tt.uploaded[i]))
+				}
+				total += f.Size
+			}
+			if transfer.TotalSize != total {
+				t.Errorf("total size = %d, want %d", transfer.TotalSize, total)
+			}
+		})
+	}
+}
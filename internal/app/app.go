@@ -0,0 +1,51 @@
+package app
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/api/middleware"
+	"github.com/rohits-web03/obscyra/internal/api/services"
+	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/rohits-web03/obscyra/internal/health"
+	"github.com/rohits-web03/obscyra/internal/mailer"
+	"github.com/rohits-web03/obscyra/internal/metrics"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+	"github.com/rohits-web03/obscyra/internal/throttle"
+	"github.com/rohits-web03/obscyra/internal/tokens"
+)
+
+// App holds the dependencies shared by the HTTP handlers and middleware.
+// Nothing in the request path reaches for package globals or the database
+// connection, so several instances, e.g. one per test with the memory
+// repositories, can run in the same process.
+type App struct {
+	Config config.Config
+	// Repos is the only way handlers reach the database
+	Repos   repositories.Repositories
+	Storage repositories.Storage
+	// Tokens signs and verifies session JWTs
+	Tokens *tokens.Keys
+	Logger *slog.Logger
+	Mailer *mailer.Mailer
+	// Providers are the external identity providers users sign in with
+	Providers *services.IdentityProviders
+	// Logins throttles failed password logins
+	Logins *throttle.Throttler
+	// RateLimits holds the token buckets of the rate limiting middleware
+	RateLimits     middleware.RateLimitStore
+	TrustedProxies middleware.TrustedProxies
+	Metrics        *metrics.Metrics
+	// Health runs the readiness checks
+	Health *health.Checker
+	// Clock returns the current time; nil means time.Now
+	Clock func() time.Time
+}
+
+// Now returns the current time according to the app's clock.
+func (a *App) Now() time.Time {
+	if a.Clock == nil {
+		return time.Now()
+	}
+	return a.Clock()
+}
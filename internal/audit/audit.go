@@ -0,0 +1,25 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/rohits-web03/obscyra/internal/repositories"
+)
+
+// Audit events
+const (
+	EventLoginSucceeded = "login.succeeded"
+	EventLoginFailed    = "login.failed"
+	EventLoginLocked    = "login.locked"
+	EventLoginBlocked   = "login.blocked"
+)
+
+// Record stores an audit log entry. Failures are logged rather than returned
+// so auditing never breaks the request being audited.
+func Record(ctx context.Context, logs repositories.AuditLogRepository, entry models.AuditLog) {
+	if err := logs.Create(ctx, &entry); err != nil {
+		slog.ErrorContext(ctx, "Failed to write audit log entry", "event", entry.Event, "error", err)
+	}
+}
@@ -1,74 +1,368 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
+	"go.yaml.in/yaml/v3"
 )
 
+// defaultJWTSecret is only accepted outside production
+const defaultJWTSecret = "not-so-secret-now-is-it?"
+
 type R2Config struct {
-	AccountID       string
-	AccessKeyID     string
-	SecretAccessKey string
-	BucketName      string
-	Region          string
-	PublicBaseURL   string
+	AccountID       string `yaml:"account_id"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	BucketName      string `yaml:"bucket_name"`
+	Region          string `yaml:"region"`
+	PublicBaseURL   string `yaml:"public_base_url"`
+}
+
+// StorageConfig selects where the encrypted files are kept.
+type StorageConfig struct {
+	Driver   string `yaml:"driver"`    // "r2" or "local"
+	LocalDir string `yaml:"local_dir"` // directory used by the local driver
+}
+
+// JWTConfig controls how session tokens are signed. Without a signing key
+// they are HS256 signed with jwt_secret and only this server can verify them.
+type JWTConfig struct {
+	// SigningKeyFile is a PEM Ed25519 or P-256 private key signing new tokens
+	SigningKeyFile string `yaml:"signing_key_file"`
+	// VerificationKeyFiles are PEM keys accepted besides the signing key, e.g.
+	// the previous key while its tokens are still valid, or the next one
+	// published ahead of a rotation
+	VerificationKeyFiles []string `yaml:"verification_key_files"`
+	Issuer               string   `yaml:"issuer"`   // defaults to base_url
+	Audience             string   `yaml:"audience"` // defaults to base_url
+}
+
+// GoogleConfig holds the OAuth client used for "Sign in with Google".
+type GoogleConfig struct {
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	RedirectURL  string `yaml:"redirect_url"` // defaults to <base_url>/api/v1/auth/google/callback
+}
+
+// OIDCProviderConfig describes a generic OpenID Connect identity provider
+// (Keycloak, Okta, Azure AD, ...). Endpoints are resolved from the issuer's
+// discovery document.
+type OIDCProviderConfig struct {
+	Name         string   `yaml:"name"`
+	IssuerURL    string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"` // defaults to <base_url>/api/v1/auth/oidc/<name>/callback
+	Scopes       []string `yaml:"scopes"`
+}
+
+// MailConfig selects how outgoing email (verification, password reset) is
+// delivered.
+type MailConfig struct {
+	Driver       string `yaml:"driver"` // "smtp" or "log"
+	From         string `yaml:"from"`
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     string `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+	LogFile      string `yaml:"log_file"` // used by the log driver, empty logs to stdout
+}
+
+// LoginThrottleConfig controls brute-force protection for password logins.
+type LoginThrottleConfig struct {
+	Store        string        `yaml:"store"` // "memory" (single node) or "postgres" (clustered)
+	FreeAttempts int           `yaml:"free_attempts"`
+	BaseLockout  time.Duration `yaml:"base_lockout"`
+	MaxLockout   time.Duration `yaml:"max_lockout"`
+	ResetAfter   time.Duration `yaml:"reset_after"`
+}
+
+// RateLimitRule allows Limit requests per Period, with bursts up to Limit.
+// The zero value disables the limit.
+type RateLimitRule struct {
+	Limit  int
+	Period time.Duration
+}
+
+// RateLimitConfig holds the rate limiting policies applied by the router.
+type RateLimitConfig struct {
+	Store          string        `yaml:"store"`           // "memory" (single node) or "postgres" (clustered)
+	TrustedProxies []string      `yaml:"trusted_proxies"` // IPs or CIDRs allowed to set X-Forwarded-For
+	Global         RateLimitRule `yaml:"global"`
+	Auth           RateLimitRule `yaml:"auth"`
+	Presign        RateLimitRule `yaml:"presign"`
+	Share          RateLimitRule `yaml:"share"`
+}
+
+// TracingConfig selects the OpenTelemetry trace exporter. The exporter's own
+// settings (endpoint, headers, sampler) are read directly by the SDK from
+// the standard OTEL_* variables.
+type TracingConfig struct {
+	Exporter string `yaml:"exporter"`
+	Protocol string `yaml:"protocol"`
+	Disabled bool   `yaml:"disabled"`
+}
+
+type ShutdownConfig struct {
+	// Delay is how long the server keeps serving after reporting not ready,
+	// so load balancers can stop routing to it first
+	Delay time.Duration `yaml:"delay"`
+	// Timeout bounds how long in-flight requests and background work may
+	// take to finish
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// CORSConfig lists the browser origins allowed to call the API with
+// credentials.
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// OAuthConfig controls where the browser may be sent after signing in
+// through an identity provider.
+type OAuthConfig struct {
+	// AllowedReturnURLs are absolute URL prefixes accepted as return_to
+	// destinations besides the frontend itself, e.g. https://admin.example.com
+	// or https://example.com/app
+	AllowedReturnURLs []string `yaml:"allowed_return_urls"`
+}
+
+// ServerConfig holds the HTTP server timeouts.
+type ServerConfig struct {
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+}
+
+// TransferConfig holds the limits applied to uploads and downloads.
+type TransferConfig struct {
+	MaxUploadSize int64         `yaml:"max_upload_size"` // bytes per transfer
+	TTL           time.Duration `yaml:"ttl"`             // how long a transfer can be downloaded
+	PresignExpiry time.Duration `yaml:"presign_expiry"`  // lifetime of presigned upload/download URLs
 }
 
 type Config struct {
-	DB_URL      string
-	Port        string
-	JWTSecret   string
-	Environment string
-	CorsConfig  cors.Options
-	R2          R2Config
+	DB_URL        string               `yaml:"db_url"`
+	AutoMigrate   bool                 `yaml:"auto_migrate"` // apply pending migrations on startup
+	Port          string               `yaml:"port"`
+	JWTSecret     string               `yaml:"jwt_secret"`
+	JWT           JWTConfig            `yaml:"jwt"`
+	SessionTTL    time.Duration        `yaml:"session_ttl"`
+	Environment   string               `yaml:"environment"`
+	LogLevel      string               `yaml:"log_level"`
+	MetricsToken  string               `yaml:"metrics_token"`
+	BaseURL       string               `yaml:"base_url"`
+	FrontendURL   string               `yaml:"frontend_url"`
+	CORS          CORSConfig           `yaml:"cors"`
+	OAuth         OAuthConfig          `yaml:"oauth"`
+	Server        ServerConfig         `yaml:"server"`
+	Transfers     TransferConfig       `yaml:"transfers"`
+	Storage       StorageConfig        `yaml:"storage"`
+	R2            R2Config             `yaml:"r2"`
+	Google        GoogleConfig         `yaml:"google"`
+	OIDCProviders []OIDCProviderConfig `yaml:"oidc_providers"`
+	Mail          MailConfig           `yaml:"mail"`
+	LoginThrottle LoginThrottleConfig  `yaml:"login_throttle"`
+	RateLimit     RateLimitConfig      `yaml:"rate_limit"`
+	Tracing       TracingConfig        `yaml:"tracing"`
+	Shutdown      ShutdownConfig       `yaml:"shutdown"`
 }
 
-var Envs = initConfig()
+// Default returns the configuration used when nothing is overridden. It is
+// suitable for local development only.
+func Default() Config {
+	return Config{
+		Port:        "8080",
+		AutoMigrate: true,
+		JWTSecret:   defaultJWTSecret,
+		SessionTTL:  24 * time.Hour,
+		Environment: "development",
+		LogLevel:    "info",
+		BaseURL:     "http://localhost:8080",
+		FrontendURL: "http://localhost:5173",
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:5173", "https://obscyra.vercel.app"},
+		},
+		Server: ServerConfig{
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  120 * time.Second,
+		},
+		Transfers: TransferConfig{
+			MaxUploadSize: 100 << 20, // 100 MB
+			TTL:           time.Hour,
+			PresignExpiry: 15 * time.Minute,
+		},
+		Storage: StorageConfig{
+			Driver:   "r2",
+			LocalDir: "data/files",
+		},
+		R2: R2Config{
+			Region: "auto",
+		},
+		Mail: MailConfig{
+			Driver:   "log",
+			From:     "Obscyra <no-reply@localhost>",
+			SMTPPort: "587",
+		},
+		LoginThrottle: LoginThrottleConfig{
+			Store:        "memory",
+			FreeAttempts: 5,
+			BaseLockout:  30 * time.Second,
+			MaxLockout:   15 * time.Minute,
+			ResetAfter:   time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			Store:   "memory",
+			Global:  RateLimitRule{Limit: 300, Period: time.Minute},
+			Auth:    RateLimitRule{Limit: 10, Period: time.Minute},
+			Presign: RateLimitRule{Limit: 30, Period: time.Minute},
+			Share:   RateLimitRule{Limit: 120, Period: time.Minute},
+		},
+		// Unlike the OTel spec there is no exporter by default, so the server
+		// works offline unless tracing is explicitly enabled
+		Tracing: TracingConfig{
+			Exporter: "none",
+			Protocol: "http/protobuf",
+		},
+		Shutdown: ShutdownConfig{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
 
-func initConfig() Config {
+// Load builds the configuration in layers: the defaults, then the YAML file
+// at path (if any), then environment variables, which take precedence. A
+// .env file (or the one named by ENV_FILE) is loaded into the environment
+// first. The result is validated, and an error describes every problem
+// found.
+func Load(path string) (Config, error) {
 	envFile := os.Getenv("ENV_FILE")
 	if envFile == "" {
 		envFile = ".env"
 	}
-	log.Println("Running in development mode, loading", envFile)
-	if err := godotenv.Load(envFile); err != nil {
-		log.Println("No", envFile, "file found")
+	if err := godotenv.Load(envFile); err != nil && os.Getenv("ENV_FILE") != "" {
+		return Config{}, fmt.Errorf("loading env file: %w", err)
 	}
 
-	return Config{
-		DB_URL:      getEnv("DB_URL", ""),
-		Port:        getEnv("PORT", "8080"),
-		JWTSecret:   getEnv("JWT_SECRET", "not-so-secret-now-is-it?"),
-		Environment: getEnv("ENV", "development"),
-		CorsConfig:  CorsConfig(),
-		R2: R2Config{
-			AccountID:       getEnv("R2_ACCOUNT_ID", ""),
-			AccessKeyID:     getEnv("R2_ACCESS_KEY_ID", ""),
-			SecretAccessKey: getEnv("R2_SECRET_ACCESS_KEY", ""),
-			BucketName:      getEnv("R2_BUCKET_NAME", ""),
-			Region:          getEnv("R2_REGION", "auto"),
-			PublicBaseURL:   getEnv("R2_PUBLIC_BASE_URL", ""),
-		},
+	cfg := Default()
+
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return Config{}, err
+	}
+
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	cfg.FrontendURL = strings.TrimRight(cfg.FrontendURL, "/")
+	for i, u := range cfg.OAuth.AllowedReturnURLs {
+		cfg.OAuth.AllowedReturnURLs[i] = strings.TrimRight(u, "/")
+	}
+	if cfg.JWT.Issuer == "" {
+		cfg.JWT.Issuer = cfg.BaseURL
+	}
+	if cfg.JWT.Audience == "" {
+		cfg.JWT.Audience = cfg.BaseURL
+	}
+	if cfg.Google.RedirectURL == "" {
+		cfg.Google.RedirectURL = cfg.BaseURL + "/api/v1/auth/google/callback"
+	}
+	for i := range cfg.OIDCProviders {
+		p := &cfg.OIDCProviders[i]
+		p.Name = strings.ToLower(p.Name)
+		if p.RedirectURL == "" {
+			p.RedirectURL = cfg.BaseURL + "/api/v1/auth/oidc/" + p.Name + "/callback"
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+		}
 	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
 }
 
-// Gets the env by key or fallbacks
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// loadFile overlays the YAML file at path onto cfg. Keys missing from the
+// file keep their current value; unknown keys are rejected so typos don't go
+// unnoticed.
+func loadFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening config file: %w", err)
 	}
-	return fallback
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
 }
 
-func CorsConfig() cors.Options {
+// IsProduction reports whether the server runs in production mode.
+func (c Config) IsProduction() bool {
+	return c.Environment == "production"
+}
+
+// CorsOptions exposes the request ID and rate limit headers so browser
+// clients can read them.
+func (c Config) CorsOptions() cors.Options {
 	return cors.Options{
-		AllowedOrigins:   []string{"http://localhost:5173","https://obscyra.vercel.app"},
+		AllowedOrigins:   c.CORS.AllowedOrigins,
 		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
-		AllowedHeaders:   []string{"*"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-CSRF-Token", "X-Request-ID"},
+		ExposedHeaders:   []string{"X-Request-ID", "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 	}
 }
+
+// ParseRateLimitRule parses a rule in the form "<limit>/<period>", e.g.
+// "100/1m". "off" disables the limit.
+func ParseRateLimitRule(value string) (RateLimitRule, error) {
+	if value == "off" {
+		return RateLimitRule{}, nil
+	}
+	limitStr, periodStr, found := strings.Cut(value, "/")
+	limit, limitErr := strconv.Atoi(limitStr)
+	period, periodErr := time.ParseDuration(periodStr)
+	if !found || limitErr != nil || periodErr != nil || limit <= 0 || period <= 0 {
+		return RateLimitRule{}, fmt.Errorf("invalid rate limit %q, expected <limit>/<period> or off", value)
+	}
+	return RateLimitRule{Limit: limit, Period: period}, nil
+}
+
+func (r RateLimitRule) String() string {
+	if r.Limit <= 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", r.Limit, r.Period)
+}
+
+func (r *RateLimitRule) UnmarshalYAML(node *yaml.Node) error {
+	rule, err := ParseRateLimitRule(node.Value)
+	if err != nil {
+		return err
+	}
+	*r = rule
+	return nil
+}
+
+func (r RateLimitRule) MarshalYAML() (any, error) {
+	return r.String(), nil
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides cfg with the environment variables that are set.
+// Variables that are present but can't be parsed are reported as errors
+// instead of silently falling back to a default.
+func applyEnv(cfg *Config) error {
+	e := &envReader{}
+
+	e.str("DB_URL", &cfg.DB_URL)
+	e.bool("DB_AUTO_MIGRATE", &cfg.AutoMigrate)
+	e.str("PORT", &cfg.Port)
+	e.str("JWT_SECRET", &cfg.JWTSecret)
+	e.str("JWT_SIGNING_KEY_FILE", &cfg.JWT.SigningKeyFile)
+	e.list("JWT_VERIFICATION_KEY_FILES", &cfg.JWT.VerificationKeyFiles)
+	e.str("JWT_ISSUER", &cfg.JWT.Issuer)
+	e.str("JWT_AUDIENCE", &cfg.JWT.Audience)
+	e.duration("SESSION_TTL", &cfg.SessionTTL)
+	e.str("ENV", &cfg.Environment)
+	e.str("LOG_LEVEL", &cfg.LogLevel)
+	e.str("METRICS_TOKEN", &cfg.MetricsToken)
+	e.str("BASE_URL", &cfg.BaseURL)
+	e.str("FRONTEND_URL", &cfg.FrontendURL)
+	e.list("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+	e.list("OAUTH_ALLOWED_RETURN_URLS", &cfg.OAuth.AllowedReturnURLs)
+
+	e.duration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	e.duration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	e.duration("SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout)
+
+	e.int64("MAX_UPLOAD_SIZE", &cfg.Transfers.MaxUploadSize)
+	e.duration("TRANSFER_TTL", &cfg.Transfers.TTL)
+	e.duration("PRESIGN_EXPIRY", &cfg.Transfers.PresignExpiry)
+
+	e.str("STORAGE_DRIVER", &cfg.Storage.Driver)
+	e.str("STORAGE_LOCAL_DIR", &cfg.Storage.LocalDir)
+
+	e.str("R2_ACCOUNT_ID", &cfg.R2.AccountID)
+	e.str("R2_ACCESS_KEY_ID", &cfg.R2.AccessKeyID)
+	e.str("R2_SECRET_ACCESS_KEY", &cfg.R2.SecretAccessKey)
+	e.str("R2_BUCKET_NAME", &cfg.R2.BucketName)
+	e.str("R2_REGION", &cfg.R2.Region)
+	e.str("R2_PUBLIC_BASE_URL", &cfg.R2.PublicBaseURL)
+
+	e.str("GOOGLE_CLIENT_ID", &cfg.Google.ClientID)
+	e.str("GOOGLE_CLIENT_SECRET", &cfg.Google.ClientSecret)
+	e.str("GOOGLE_REDIRECT_URL", &cfg.Google.RedirectURL)
+
+	e.oidcProviders(&cfg.OIDCProviders)
+
+	e.str("MAIL_DRIVER", &cfg.Mail.Driver)
+	e.str("MAIL_FROM", &cfg.Mail.From)
+	e.str("SMTP_HOST", &cfg.Mail.SMTPHost)
+	e.str("SMTP_PORT", &cfg.Mail.SMTPPort)
+	e.str("SMTP_USERNAME", &cfg.Mail.SMTPUsername)
+	e.str("SMTP_PASSWORD", &cfg.Mail.SMTPPassword)
+	e.str("MAIL_LOG_FILE", &cfg.Mail.LogFile)
+
+	e.str("LOGIN_THROTTLE_STORE", &cfg.LoginThrottle.Store)
+	e.int("LOGIN_THROTTLE_FREE_ATTEMPTS", &cfg.LoginThrottle.FreeAttempts)
+	e.duration("LOGIN_THROTTLE_BASE_LOCKOUT", &cfg.LoginThrottle.BaseLockout)
+	e.duration("LOGIN_THROTTLE_MAX_LOCKOUT", &cfg.LoginThrottle.MaxLockout)
+	e.duration("LOGIN_THROTTLE_RESET_AFTER", &cfg.LoginThrottle.ResetAfter)
+
+	e.str("RATE_LIMIT_STORE", &cfg.RateLimit.Store)
+	e.list("TRUSTED_PROXIES", &cfg.RateLimit.TrustedProxies)
+	e.rateLimit("RATE_LIMIT_GLOBAL", &cfg.RateLimit.Global)
+	e.rateLimit("RATE_LIMIT_AUTH", &cfg.RateLimit.Auth)
+	e.rateLimit("RATE_LIMIT_PRESIGN", &cfg.RateLimit.Presign)
+	e.rateLimit("RATE_LIMIT_SHARE", &cfg.RateLimit.Share)
+
+	e.str("OTEL_TRACES_EXPORTER", &cfg.Tracing.Exporter)
+	e.str("OTEL_EXPORTER_OTLP_PROTOCOL", &cfg.Tracing.Protocol)
+	e.str("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", &cfg.Tracing.Protocol)
+	e.bool("OTEL_SDK_DISABLED", &cfg.Tracing.Disabled)
+
+	e.duration("SHUTDOWN_DELAY", &cfg.Shutdown.Delay)
+	e.duration("SHUTDOWN_TIMEOUT", &cfg.Shutdown.Timeout)
+
+	return errors.Join(e.errs...)
+}
+
+// envReader reads typed environment variables, collecting parse errors.
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) fail(key, value string, err error) {
+	e.errs = append(e.errs, fmt.Errorf("%s=%q: %w", key, value, err))
+}
+
+func (e *envReader) str(key string, dst *string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*dst = value
+	}
+}
+
+func (e *envReader) list(key string, dst *[]string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*dst = splitList(value)
+	}
+}
+
+func (e *envReader) int(key string, dst *int) {
+	if value, ok := os.LookupEnv(key); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			e.fail(key, value, errors.New("not an integer"))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envReader) int64(key string, dst *int64) {
+	if value, ok := os.LookupEnv(key); ok {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			e.fail(key, value, errors.New("not an integer"))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envReader) bool(key string, dst *bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			e.fail(key, value, errors.New("not a boolean"))
+			return
+		}
+		*dst = b
+	}
+}
+
+// duration reads values such as "30s" or "15m"
+func (e *envReader) duration(key string, dst *time.Duration) {
+	if value, ok := os.LookupEnv(key); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			e.fail(key, value, errors.New("not a duration"))
+			return
+		}
+		*dst = d
+	}
+}
+
+func (e *envReader) rateLimit(key string, dst *RateLimitRule) {
+	if value, ok := os.LookupEnv(key); ok {
+		rule, err := ParseRateLimitRule(value)
+		if err != nil {
+			e.fail(key, value, err)
+			return
+		}
+		*dst = rule
+	}
+}
+
+// oidcProviders reads the provider registry from the environment, replacing
+// any providers from the config file. OIDC_PROVIDERS holds a comma separated
+// list of provider names and each provider is configured through
+// OIDC_<NAME>_* variables, e.g.
+//
+//	OIDC_PROVIDERS=keycloak
+//	OIDC_KEYCLOAK_ISSUER=https://sso.example.com/realms/acme
+//	OIDC_KEYCLOAK_CLIENT_ID=obscyra
+//	OIDC_KEYCLOAK_CLIENT_SECRET=...
+func (e *envReader) oidcProviders(dst *[]OIDCProviderConfig) {
+	names, ok := os.LookupEnv("OIDC_PROVIDERS")
+	if !ok {
+		return
+	}
+
+	var providers []OIDCProviderConfig
+	for _, name := range splitList(names) {
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		p := OIDCProviderConfig{Name: name}
+		e.str(prefix+"ISSUER", &p.IssuerURL)
+		e.str(prefix+"CLIENT_ID", &p.ClientID)
+		e.str(prefix+"CLIENT_SECRET", &p.ClientSecret)
+		e.str(prefix+"REDIRECT_URL", &p.RedirectURL)
+		e.list(prefix+"SCOPES", &p.Scopes)
+		providers = append(providers, p)
+	}
+	*dst = providers
+}
+
+// splitList splits a comma separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
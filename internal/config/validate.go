@@ -0,0 +1,212 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// minSecretLength is the shortest JWT secret accepted in production
+const minSecretLength = 32
+
+const redacted = "[REDACTED]"
+
+// Validate checks that required settings are present and consistent, and
+// that production doesn't run with development defaults. It reports every
+// problem at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Environment == "development" || c.Environment == "production",
+		"environment must be development or production, got %q", c.Environment)
+	check(oneOf(c.LogLevel, "debug", "info", "warn", "error"), "log_level %q is not one of debug, info, warn, error", c.LogLevel)
+
+	check(c.DB_URL != "", "db_url (DB_URL) is required")
+	check(c.JWTSecret != "", "jwt_secret (JWT_SECRET) is required")
+	check(c.SessionTTL > 0, "session_ttl must be positive")
+	check(len(c.JWT.VerificationKeyFiles) == 0 || c.JWT.SigningKeyFile != "",
+		"jwt.verification_key_files require jwt.signing_key_file")
+	check(c.Port != "", "port is required")
+
+	check(validURL(c.BaseURL), "base_url %q must be an absolute http(s) URL", c.BaseURL)
+	check(validURL(c.FrontendURL), "frontend_url %q must be an absolute http(s) URL", c.FrontendURL)
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(validURL(origin), "cors origin %q must be an absolute http(s) URL, wildcards can't be used with credentials", origin)
+	}
+	for _, u := range c.OAuth.AllowedReturnURLs {
+		check(validReturnURL(u), "oauth return url %q must be an absolute http(s) URL without query or fragment", u)
+	}
+
+	check(c.Server.ReadTimeout > 0 && c.Server.WriteTimeout > 0 && c.Server.IdleTimeout > 0,
+		"server timeouts must be positive")
+	check(c.Transfers.MaxUploadSize > 0, "transfers.max_upload_size must be positive")
+	check(c.Transfers.TTL > 0, "transfers.ttl must be positive")
+	check(c.Transfers.PresignExpiry > 0, "transfers.presign_expiry must be positive")
+	check(c.Shutdown.Timeout > 0, "shutdown.timeout must be positive")
+	check(c.Shutdown.Delay >= 0, "shutdown.delay must not be negative")
+
+	check(oneOf(c.Storage.Driver, "r2", "local"), "storage.driver must be r2 or local, got %q", c.Storage.Driver)
+	switch c.Storage.Driver {
+	case "r2":
+		check(c.R2.AccountID != "" && c.R2.AccessKeyID != "" && c.R2.SecretAccessKey != "" && c.R2.BucketName != "",
+			"r2 account_id, access_key_id, secret_access_key and bucket_name are required")
+	case "local":
+		check(c.Storage.LocalDir != "", "storage.local_dir (STORAGE_LOCAL_DIR) is required for the local storage driver")
+	}
+
+	if c.Google.ClientID != "" {
+		check(c.Google.ClientSecret != "", "google.client_secret is required when google.client_id is set")
+		check(validURL(c.Google.RedirectURL), "google.redirect_url %q must be an absolute http(s) URL", c.Google.RedirectURL)
+	}
+	providerNames := make(map[string]bool, len(c.OIDCProviders))
+	for _, p := range c.OIDCProviders {
+		check(p.Name != "", "oidc provider name is required")
+		// Google has its own login routes
+		check(p.Name != "google", "oidc provider name %q is reserved", p.Name)
+		check(!providerNames[p.Name], "oidc provider %q is configured twice", p.Name)
+		providerNames[p.Name] = true
+		check(validURL(p.IssuerURL), "oidc provider %q issuer %q must be an absolute http(s) URL", p.Name, p.IssuerURL)
+		check(p.ClientID != "", "oidc provider %q client_id is required", p.Name)
+		check(validURL(p.RedirectURL), "oidc provider %q redirect_url %q must be an absolute http(s) URL", p.Name, p.RedirectURL)
+	}
+
+	check(oneOf(c.Mail.Driver, "smtp", "log"), "mail.driver must be smtp or log, got %q", c.Mail.Driver)
+	if c.Mail.Driver == "smtp" {
+		check(c.Mail.SMTPHost != "", "mail.smtp_host (SMTP_HOST) is required for the smtp mail driver")
+	}
+
+	check(oneOf(c.LoginThrottle.Store, "memory", "postgres"), "login_throttle.store must be memory or postgres, got %q", c.LoginThrottle.Store)
+	check(oneOf(c.RateLimit.Store, "memory", "postgres"), "rate_limit.store must be memory or postgres, got %q", c.RateLimit.Store)
+
+	if c.IsProduction() {
+		check(c.JWTSecret != defaultJWTSecret, "jwt_secret must be changed from the default in production")
+		check(len(c.JWTSecret) >= minSecretLength, "jwt_secret must be at least %d characters in production", minSecretLength)
+		check(strings.HasPrefix(c.BaseURL, "https://"), "base_url must use https in production")
+		check(strings.HasPrefix(c.FrontendURL, "https://"), "frontend_url must use https in production")
+		for _, u := range c.OAuth.AllowedReturnURLs {
+			check(strings.HasPrefix(u, "https://"), "oauth return url %q must use https in production", u)
+		}
+		// Logged messages contain password reset and verification links
+		check(c.Mail.Driver != "log", "mail.driver must be smtp in production, the log driver writes reset links to the log")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Warnings lists settings that are accepted but shouldn't be used outside
+// local development.
+func (c Config) Warnings() []string {
+	var warnings []string
+	if c.JWTSecret == defaultJWTSecret {
+		warnings = append(warnings, "using the default JWT secret; set JWT_SECRET")
+	}
+	if c.IsProduction() && c.MetricsToken == "" {
+		warnings = append(warnings, "/metrics is unauthenticated; set METRICS_TOKEN to protect it")
+	}
+	return warnings
+}
+
+// Redacted returns a copy of the configuration with secrets masked, safe to
+// log or print.
+func (c Config) Redacted() Config {
+	mask := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return redacted
+	}
+
+	c.DB_URL = redactDSN(c.DB_URL)
+	c.JWTSecret = mask(c.JWTSecret)
+	c.MetricsToken = mask(c.MetricsToken)
+	c.R2.AccessKeyID = mask(c.R2.AccessKeyID)
+	c.R2.SecretAccessKey = mask(c.R2.SecretAccessKey)
+	c.Google.ClientSecret = mask(c.Google.ClientSecret)
+	c.Mail.SMTPPassword = mask(c.Mail.SMTPPassword)
+
+	providers := make([]OIDCProviderConfig, len(c.OIDCProviders))
+	for i, p := range c.OIDCProviders {
+		p.ClientSecret = mask(p.ClientSecret)
+		providers[i] = p
+	}
+	c.OIDCProviders = providers
+	return c
+}
+
+// YAML renders the redacted configuration in the config file format.
+func (c Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// LogValue logs the configuration redacted, keyed like the config file.
+func (c Config) LogValue() slog.Value {
+	out, err := c.YAML()
+	if err != nil {
+		return slog.StringValue("unavailable: " + err.Error())
+	}
+	var m map[string]any
+	if err := yaml.Unmarshal(out, &m); err != nil {
+		return slog.StringValue("unavailable: " + err.Error())
+	}
+	return slog.AnyValue(m)
+}
+
+// redactDSN masks the password in a postgres URL or key=value DSN.
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "REDACTED")
+		}
+		return u.String()
+	}
+
+	fields := strings.Fields(dsn)
+	for i, f := range fields {
+		if key, _, ok := strings.Cut(f, "="); ok && strings.EqualFold(key, "password") {
+			fields[i] = key + "=" + redacted
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validReturnURL reports whether s can be used as a return_to prefix
+func validReturnURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && validURL(s) && u.User == nil && u.RawQuery == "" && u.Fragment == ""
+}
+
+func oneOf(value string, options ...string) bool {
+	for _, o := range options {
+		if value == o {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateOIDCProviders(t *testing.T) {
+	valid := OIDCProviderConfig{
+		Name:        "okta",
+		IssuerURL:   "https://example.okta.com",
+		ClientID:    "client",
+		RedirectURL: "http://localhost:8080/api/v1/auth/oidc/okta/callback",
+	}
+	with := func(edit func(*OIDCProviderConfig)) OIDCProviderConfig {
+		p := valid
+		edit(&p)
+		return p
+	}
+
+	tests := []struct {
+		name      string
+		providers []OIDCProviderConfig
+		wantErr   string // empty for a valid configuration
+	}{
+		{name: "valid", providers: []OIDCProviderConfig{valid}},
+		{name: "missing name", providers: []OIDCProviderConfig{with(func(p *OIDCProviderConfig) { p.Name = "" })}, wantErr: "oidc provider name is required"},
+		{name: "reserved name", providers: []OIDCProviderConfig{with(func(p *OIDCProviderConfig) { p.Name = "google" })}, wantErr: `oidc provider name "google" is reserved`},
+		{name: "duplicate name", providers: []OIDCProviderConfig{valid, valid}, wantErr: `oidc provider "okta" is configured twice`},
+		{name: "missing issuer", providers: []OIDCProviderConfig{with(func(p *OIDCProviderConfig) { p.IssuerURL = "" })}, wantErr: `oidc provider "okta" issuer "" must be an absolute http(s) URL`},
+		{name: "missing client id", providers: []OIDCProviderConfig{with(func(p *OIDCProviderConfig) { p.ClientID = "" })}, wantErr: `oidc provider "okta" client_id is required`},
+		{name: "relative redirect", providers: []OIDCProviderConfig{with(func(p *OIDCProviderConfig) { p.RedirectURL = "/callback" })}, wantErr: `oidc provider "okta" redirect_url "/callback" must be an absolute http(s) URL`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.DB_URL = "postgres://localhost/obscyra"
+			cfg.Storage = StorageConfig{Driver: "local", LocalDir: t.TempDir()}
+			cfg.OIDCProviders = tt.providers
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate returned %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/utils"
+)
+
+// CheckFunc reports whether a dependency is usable. It should return
+// promptly once ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult is the outcome of a single dependency check.
+type CheckResult struct {
+	Status     string  `json:"status"` // "ok" or "failed"
+	DurationMs float64 `json:"durationMs"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// ReadinessReport is the body of a readiness response.
+type ReadinessReport struct {
+	Status string                 `json:"status"` // "ready", "not_ready" or "draining"
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker holds the dependencies checked by the readiness probe and whether
+// the server is draining.
+type Checker struct {
+	mu     sync.RWMutex
+	checks []check
+
+	draining atomic.Bool
+
+	// Timeout bounds each check so a hanging dependency can't stall the probe
+	Timeout time.Duration
+}
+
+// New returns a Checker without dependencies and the default timeout.
+func New() *Checker {
+	return &Checker{Timeout: 2 * time.Second}
+}
+
+// Register adds a dependency checked by the readiness probe.
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// SetDraining marks the server as shutting down. Readiness fails from then
+// on so load balancers stop routing new requests, while liveness still
+// succeeds so the process isn't killed before in-flight requests finish.
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+// Check runs all registered checks concurrently and reports whether the
+// server is ready to take traffic.
+func (c *Checker) Check(ctx context.Context) (ReadinessReport, bool) {
+	c.mu.RLock()
+	registered := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make([]CheckResult, len(registered))
+	var wg sync.WaitGroup
+	for i, chk := range registered {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, chk.fn, c.Timeout)
+		}()
+	}
+	wg.Wait()
+
+	report := ReadinessReport{
+		Status: "ready",
+		Checks: make(map[string]CheckResult, len(registered)),
+	}
+	for i, chk := range registered {
+		report.Checks[chk.name] = results[i]
+		if results[i].Status != "ok" {
+			report.Status = "not_ready"
+		}
+	}
+	if c.draining.Load() {
+		report.Status = "draining"
+	}
+	return report, report.Status == "ready"
+}
+
+func run(ctx context.Context, fn CheckFunc, timeout time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := fn(ctx)
+	result := CheckResult{
+		Status:     "ok",
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// GET /livez
+// Livez godoc
+// @Summary Liveness probe
+// @Description Reports that the process is running and able to serve HTTP. It doesn't check dependencies, so a failing database never gets the server restarted.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} utils.Payload "Alive"
+// @Router /livez [get]
+func Livez(w http.ResponseWriter, r *http.Request) {
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "Alive",
+	})
+}
+
+// GET /readyz
+// Readyz godoc
+// @Summary Readiness probe
+// @Description Checks the database and object storage and reports the result of each. Returns 503 when a dependency is unavailable or the server is shutting down.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} utils.Payload{data=ReadinessReport} "Ready"
+// @Failure 503 {object} utils.Payload{data=ReadinessReport} "Not ready"
+// @Router /readyz [get]
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	report, ready := c.Check(r.Context())
+	if !ready {
+		utils.JSONResponse(w, utils.CodeUnavailable.Status(), utils.Payload{
+			Success: false,
+			Message: "Not ready",
+			Error:   &utils.ErrorBody{Code: utils.CodeUnavailable, Message: "Not ready"},
+			Data:    report,
+		})
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.Payload{
+		Success: true,
+		Message: "Ready",
+		Data:    report,
+	})
+}
@@ -0,0 +1,69 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// Component is a subsystem with a start and stop step. Either may be nil.
+type Component struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+// Manager starts components in the order they were added and stops them in
+// reverse, so a component can rely on everything added before it for its
+// whole lifetime.
+type Manager struct {
+	components []Component
+	started    []Component
+}
+
+func New() *Manager {
+	return &Manager{}
+}
+
+// Add appends a component to the start order.
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+// Start starts every component in order. If one fails, the components that
+// were already started are stopped again and the error is returned.
+func (m *Manager) Start(ctx context.Context) error {
+	for _, c := range m.components {
+		if c.Start != nil {
+			if err := c.Start(ctx); err != nil {
+				err = fmt.Errorf("start %s: %w", c.Name, err)
+				return errors.Join(err, m.Stop(context.WithoutCancel(ctx)))
+			}
+		}
+		m.started = append(m.started, c)
+		slog.Debug("Component started", "component", c.Name)
+	}
+	return nil
+}
+
+// Stop stops the started components in reverse order. Every component is
+// given the chance to stop even if an earlier one failed or ctx expired;
+// ctx bounds the total time they may take.
+func (m *Manager) Stop(ctx context.Context) error {
+	var errs []error
+	for i := len(m.started) - 1; i >= 0; i-- {
+		c := m.started[i]
+		if c.Stop == nil {
+			continue
+		}
+		if err := c.Stop(ctx); err != nil {
+			slog.Error("Component failed to stop", "component", c.Name, "error", err)
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.Name, err))
+			continue
+		}
+		slog.Debug("Component stopped", "component", c.Name)
+	}
+	m.started = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestInfo carries per-request fields that are attached to every log line
+// written with the request context. It is stored as a pointer so that inner
+// middleware (e.g. authentication) can fill in fields seen by outer ones.
+type RequestInfo struct {
+	ID     string
+	UserID string
+	// Route is the pattern of the route that handled the request, e.g.
+	// /api/v1/share/{token}. Empty if no route matched.
+	Route string
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a context carrying the request info.
+func WithRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFrom returns the request info stored in the context, or nil.
+func RequestInfoFrom(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info
+}
+
+// RequestID returns the ID of the request the context belongs to, if any.
+func RequestID(ctx context.Context) string {
+	if info := RequestInfoFrom(ctx); info != nil {
+		return info.ID
+	}
+	return ""
+}
+
+// contextHandler adds the request ID, user ID and trace IDs from the context
+// to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info := RequestInfoFrom(ctx); info != nil {
+		if info.ID != "" {
+			r.AddAttrs(slog.String("request_id", info.ID))
+		}
+		if info.UserID != "" {
+			r.AddAttrs(slog.String("user_id", info.UserID))
+		}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Setup installs a JSON logger as the slog default. Output from the standard
+// log package is routed through it as well.
+func Setup(level string) {
+	var lvl slog.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		lvl = slog.LevelDebug
+	case "warn", "warning":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		lvl = slog.LevelInfo
+	}
+
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: lvl})
+	slog.SetDefault(slog.New(contextHandler{handler}))
+}
+
+// Fatal logs an error and exits the process.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogSender writes messages to a file instead of sending them, or to the
+// server log when no file is configured. Intended for local development.
+type LogSender struct {
+	Path string
+	From string
+
+	mu sync.Mutex
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	if err := validateHeaders(msg.To, msg.Subject); err != nil {
+		return err
+	}
+
+	entry := fmt.Sprintf("Date: %s\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC1123Z), s.From, msg.To, msg.Subject, msg.Body)
+
+	if s.Path == "" {
+		slog.InfoContext(ctx, "Outgoing email", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(entry)
+	return err
+}
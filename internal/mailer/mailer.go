@@ -0,0 +1,109 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/config"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email messages.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Mailer delivers messages through a Sender and tracks the deliveries
+// still running in the background.
+type Mailer struct {
+	sender  Sender
+	pending sync.WaitGroup
+}
+
+// NewMailer returns a Mailer delivering through sender.
+func NewMailer(sender Sender) *Mailer {
+	return &Mailer{sender: sender}
+}
+
+// New selects the email sender from configuration. The "smtp" driver sends
+// real mail; the "log" driver writes messages to a file (or the server log)
+// for local development.
+func New(cfg config.MailConfig) (*Mailer, error) {
+	var sender Sender
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for the smtp mail driver")
+		}
+		sender = &SMTPSender{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}
+	case "", "log":
+		sender = &LogSender{Path: cfg.LogFile, From: cfg.From}
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+
+	slog.Info("Mail driver initialized", "driver", cfg.Driver)
+	return NewMailer(sender), nil
+}
+
+// Send delivers a message through the configured sender.
+func (m *Mailer) Send(ctx context.Context, msg Message) error {
+	return m.sender.Send(ctx, msg)
+}
+
+// SendAsync delivers a message in the background, logging failures. The
+// context's values are kept for logging but not its cancellation, so the
+// delivery outlives the request that triggered it.
+func (m *Mailer) SendAsync(ctx context.Context, msg Message) {
+	ctx = context.WithoutCancel(ctx)
+	m.pending.Add(1)
+	go func() {
+		defer m.pending.Done()
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err := m.Send(ctx, msg); err != nil {
+			slog.ErrorContext(ctx, "Failed to send email", "subject", msg.Subject, "error", err)
+		}
+	}()
+}
+
+// Wait blocks until background deliveries have finished or ctx is done.
+func (m *Mailer) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for pending emails: %w", ctx.Err())
+	}
+}
+
+// validateHeaders rejects header values that would allow header injection
+func validateHeaders(values ...string) error {
+	for _, v := range values {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("invalid header value %q", v)
+		}
+	}
+	return nil
+}
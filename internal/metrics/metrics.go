@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"gorm.io/gorm"
+)
+
+const namespace = "obscyra"
+
+// Metrics holds the Obscyra collectors in a registry of their own. A
+// dedicated registry is used instead of the global default so only the
+// metrics registered here are exposed, and every App gets its own. The
+// methods recording observations do nothing on a nil *Metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests      *prometheus.CounterVec
+	httpDuration      *prometheus.HistogramVec
+	uploadPresigns    prometheus.Counter
+	uploadCompletions prometheus.Counter
+	downloadPresigns  prometheus.Counter
+	transferBytes     prometheus.Histogram
+	storageDuration   *prometheus.HistogramVec
+}
+
+// New creates the collectors and registers them with the Go runtime and
+// process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency, by method, route pattern and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+
+		uploadPresigns: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upload_presigns_total",
+			Help:      "Presigned upload URLs issued, one per file.",
+		}),
+
+		uploadCompletions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upload_completions_total",
+			Help:      "Transfers completed and stored.",
+		}),
+
+		downloadPresigns: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "download_presigns_total",
+			Help:      "Presigned download URLs issued to recipients.",
+		}),
+
+		transferBytes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "transfer_bytes",
+			Help:      "Total bytes uploaded per completed transfer.",
+			// 64KiB up to 1GiB
+			Buckets: prometheus.ExponentialBuckets(64*1024, 4, 8),
+		}),
+
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Object storage call latency, by operation and outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.uploadPresigns,
+		m.uploadCompletions,
+		m.downloadPresigns,
+		m.transferBytes,
+		m.storageDuration,
+	)
+	return m
+}
+
+// RegisterDB exposes the connection pool statistics of the database and a
+// gauge of transfers that have not expired yet.
+func (m *Metrics) RegisterDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	activeTransfers := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_transfers",
+		Help:      "Transfers that are neither deleted nor expired.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		var count int64
+		if err := db.WithContext(ctx).Model(&models.Transfer{}).
+			Where("deleted = ? AND expires_at > ?", false, time.Now()).
+			Count(&count).Error; err != nil {
+			slog.Warn("Failed to count active transfers", "error", err)
+			return math.NaN()
+		}
+		return float64(count)
+	})
+
+	for _, c := range []prometheus.Collector{
+		collectors.NewDBStatsCollector(sqlDB, db.Dialector.Name()),
+		activeTransfers,
+	} {
+		if err := m.registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ObserveRequest records a handled HTTP request.
+func (m *Metrics) ObserveRequest(method, route, status string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpRequests.WithLabelValues(method, route, status).Inc()
+	m.httpDuration.WithLabelValues(method, route, status).Observe(duration.Seconds())
+}
+
+// ObserveUploadPresigns records n presigned upload URLs.
+func (m *Metrics) ObserveUploadPresigns(n int) {
+	if m == nil {
+		return
+	}
+	m.uploadPresigns.Add(float64(n))
+}
+
+// ObserveUploadCompletion records a completed transfer of totalSize bytes.
+func (m *Metrics) ObserveUploadCompletion(totalSize int64) {
+	if m == nil {
+		return
+	}
+	m.uploadCompletions.Inc()
+	m.transferBytes.Observe(float64(totalSize))
+}
+
+// ObserveDownloadPresign records a presigned download URL.
+func (m *Metrics) ObserveDownloadPresign() {
+	if m == nil {
+		return
+	}
+	m.downloadPresigns.Inc()
+}
+
+// ObserveStorage records the latency of an object storage call started at
+// start.
+func (m *Metrics) ObserveStorage(operation string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	m.storageDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
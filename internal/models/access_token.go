@@ -0,0 +1,48 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes limit what a personal access token may do. Sessions have every
+// scope.
+const (
+	ScopeTransfersWrite = "transfers:write" // create transfers
+	ScopeInboxRead      = "inbox:read"      // list and download transfers shared with the user
+)
+
+// Scopes lists every scope a token can be granted.
+var Scopes = []string{ScopeTransfersWrite, ScopeInboxRead}
+
+// AccessTokenPrefix starts every personal access token, so they can be told
+// apart from session JWTs and spotted by secret scanners.
+const AccessTokenPrefix = "obs_pat_"
+
+// AccessToken is a personal access token used by scripts and CLIs instead of
+// a session. Only a hash of the token is stored.
+type AccessToken struct {
+	ID     uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID uuid.UUID `json:"-" gorm:"type:uuid;not null;index"`
+	Name   string    `json:"name" gorm:"not null"`
+	// Hint is the end of the token, shown to help users recognise it
+	Hint       string     `json:"hint" gorm:"not null"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes     string     `json:"-" gorm:"not null"` // space separated
+	ExpiresAt  *time.Time `json:"expiresAt"`         // nil for tokens that don't expire
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// ScopeList returns the scopes granted to the token.
+func (t *AccessToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// HasScope reports whether the token was granted scope.
+func (t *AccessToken) HasScope(scope string) bool {
+	return slices.Contains(t.ScopeList(), scope)
+}
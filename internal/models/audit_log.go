@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditLog records a security relevant event, such as a failed login or an
+// account lockout.
+type AuditLog struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	Event     string     `json:"event" gorm:"not null;index"`
+	UserID    *uuid.UUID `json:"userId" gorm:"type:uuid;index"`
+	Username  string     `json:"username"`
+	IP        string     `json:"ip"`
+	UserAgent string     `json:"userAgent"`
+	Detail    string     `json:"detail"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"autoCreateTime;index"`
+}
@@ -7,7 +7,7 @@ import (
 )
 
 type File struct {
-	ID          uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
 	TransferID  uuid.UUID `json:"transferId" gorm:"type:uuid;index;not null"` // foreign key
 	Filename    string    `json:"filename" gorm:"not null"`
 	Size        int64     `json:"size" gorm:"not null"` // bytes
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IDs are generated here rather than by a database default, so inserts work
+// the same on every supported database.
+
+// assignID sets id to a new random UUID unless it was set by the caller
+func assignID(id *uuid.UUID) {
+	if *id == uuid.Nil {
+		*id = uuid.New()
+	}
+}
+
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	assignID(&u.ID)
+	return nil
+}
+
+func (t *Transfer) BeforeCreate(tx *gorm.DB) error {
+	assignID(&t.ID)
+	return nil
+}
+
+func (f *File) BeforeCreate(tx *gorm.DB) error {
+	assignID(&f.ID)
+	return nil
+}
+
+func (r *Recipient) BeforeCreate(tx *gorm.DB) error {
+	assignID(&r.ID)
+	return nil
+}
+
+func (i *Identity) BeforeCreate(tx *gorm.DB) error {
+	assignID(&i.ID)
+	return nil
+}
+
+func (t *UserToken) BeforeCreate(tx *gorm.DB) error {
+	assignID(&t.ID)
+	return nil
+}
+
+func (l *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	assignID(&l.ID)
+	return nil
+}
+
+func (t *AccessToken) BeforeCreate(tx *gorm.DB) error {
+	assignID(&t.ID)
+	return nil
+}
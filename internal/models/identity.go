@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Identity links a user to an account at an external identity provider
+// (Google or a configured OIDC provider). Logins through a provider are
+// matched on the provider's stable subject identifier, never on email.
+type Identity struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `json:"userId" gorm:"type:uuid;not null;index;uniqueIndex:idx_identities_user_provider"`
+	Provider  string    `json:"provider" gorm:"not null;uniqueIndex:idx_identities_provider_subject;uniqueIndex:idx_identities_user_provider"`
+	Subject   string    `json:"-" gorm:"not null;uniqueIndex:idx_identities_provider_subject"` // "sub" claim at the provider
+	Email     string    `json:"email"`                                                         // email reported by the provider when linked
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
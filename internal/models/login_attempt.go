@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginAttempt tracks failed logins for a throttling key (a username or a
+// client IP) when login throttling uses the postgres store.
+type LoginAttempt struct {
+	Key         string     `json:"key" gorm:"primaryKey"`
+	Failures    int        `json:"failures" gorm:"not null;default:0"`
+	LastFailure *time.Time `json:"lastFailure"`
+	LockedUntil *time.Time `json:"lockedUntil"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
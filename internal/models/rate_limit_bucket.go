@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RateLimitBucket is the state of a token bucket when rate limiting uses the
+// postgres store.
+type RateLimitBucket struct {
+	Key        string    `json:"key" gorm:"primaryKey"`
+	Tokens     float64   `json:"tokens" gorm:"not null"`
+	RefilledAt time.Time `json:"refilledAt" gorm:"not null"` // when Tokens was last refilled
+}
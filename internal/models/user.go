@@ -7,12 +7,15 @@ import (
 )
 
 type User struct {
-	ID                  uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
-	Username            string    `json:"username" gorm:"uniqueIndex;not null"`
-	Email               string    `json:"email" gorm:"uniqueIndex;not null"`
-	Password            string    `json:"-" gorm:"not null"`
-	PublicKey           string    `json:"publicKey" gorm:"type:text"`           // Visible to everyone
-	EncryptedPrivateKey string    `json:"encryptedPrivateKey" gorm:"type:text"` // JSON blob: { key, iv }
-	CreatedAt           time.Time `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt           time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	ID                  uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	Username            string     `json:"username" gorm:"uniqueIndex;not null"`
+	Email               string     `json:"email" gorm:"uniqueIndex;not null"`
+	Password            string     `json:"-" gorm:"not null"`
+	PublicKey           string     `json:"publicKey" gorm:"type:text"`           // Visible to everyone
+	EncryptedPrivateKey string     `json:"encryptedPrivateKey" gorm:"type:text"` // JSON blob: { key, iv }
+	EmailVerified       bool       `json:"emailVerified" gorm:"not null;default:false"`
+	KeysResetAt         *time.Time `json:"keysResetAt"`                 // set when a password reset replaced the key pair
+	SessionVersion      int        `json:"-" gorm:"not null;default:0"` // bumped to revoke all issued sessions
+	CreatedAt           time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt           time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
 }
@@ -1,32 +1,117 @@
 package repositories
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 
-	"github.com/rohits-web03/obscyra/internal/config"
-	"github.com/rohits-web03/obscyra/internal/models"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
+// ConnectDatabase opens the connection pool. The driver is picked from the
+// URL scheme: postgres:// (or a key=value DSN) for Postgres and sqlite://
+// followed by a file path for SQLite, e.g. sqlite://data/obscyra.db. The
+// schema is managed by MigrateUp.
+func ConnectDatabase(dsn string) (*gorm.DB, error) {
+	dialector, err := openDialector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	// TranslateError turns unique violations into gorm.ErrDuplicatedKey,
+	// which the repositories report as ErrDuplicate
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	if db.Dialector.Name() == "sqlite" && strings.Contains(dsn, ":memory:") {
+		// Every connection to :memory: gets its own empty database
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+	// Trace queries under the span of the request that issued them
+	if err := db.Use(tracingPlugin{}); err != nil {
+		return nil, fmt.Errorf("enabling database tracing: %w", err)
+	}
+	slog.Info("Successfully connected to database", "driver", db.Dialector.Name())
+	return db, nil
+}
+
+func openDialector(dsn string) (gorm.Dialector, error) {
+	scheme, rest, found := strings.Cut(dsn, "://")
+	if !found {
+		// key=value connection string
+		return postgres.Open(dsn), nil
+	}
+	switch scheme {
+	case "postgres", "postgresql":
+		return postgres.Open(dsn), nil
+	case "sqlite", "sqlite3":
+		sqliteDSN, err := sqliteDSN(rest)
+		if err != nil {
+			return nil, err
+		}
+		return sqlite.Open(sqliteDSN), nil
+	default:
+		return nil, fmt.Errorf("unsupported database URL scheme %q, expected postgres:// or sqlite://", scheme)
+	}
+}
 
-func ConnectDatabase() {
-	dsn := config.Envs.DB_URL
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// sqliteDSN turns the part of a sqlite:// URL after the scheme into a driver
+// DSN. The database directory is created if needed. Foreign keys are
+// enforced, WAL lets readers run alongside the writer, and transactions take
+// the write lock up front so concurrent writers wait on busy_timeout instead
+// of failing when upgrading a read lock.
+func sqliteDSN(rest string) (string, error) {
+	path, query, _ := strings.Cut(rest, "?")
+	if path == "" {
+		return "", errors.New("sqlite database URL has no file path")
+	}
+	params, err := url.ParseQuery(query)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-	// Run migrations
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Transfer{},
-		&models.File{},
-		&models.Recipient{},
-	)
+		return "", fmt.Errorf("invalid sqlite database URL parameters: %w", err)
+	}
+
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			return "", fmt.Errorf("creating database directory: %w", err)
+		}
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	if !params.Has("_txlock") {
+		params.Set("_txlock", "immediate")
+	}
+	if !params.Has("_time_format") {
+		params.Set("_time_format", "sqlite")
+	}
+	return path + "?" + params.Encode(), nil
+}
+
+// PingDB checks that a database connection can be established.
+func PingDB(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// CloseDatabase closes the connection pool.
+func CloseDatabase(db *gorm.DB) error {
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatal("Migration failed:", err)
+		return err
 	}
-	DB = db
-	log.Println("Successfully connected to database")
+	return sqlDB.Close()
 }
@@ -0,0 +1,302 @@
+package repositories
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/metrics"
+	"github.com/rohits-web03/obscyra/internal/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LocalStoragePath is where the API serves the files of a LocalStorage.
+const LocalStoragePath = "/api/v1/storage"
+
+// localTransferTimeout bounds a single upload or download through the local
+// store, overriding the server's request timeouts which are too short for
+// large files
+const localTransferTimeout = 30 * time.Minute
+
+// LocalStorage keeps files in a directory on local disk, for single-node
+// deployments. It signs its own presigned URLs, which the API serves under
+// LocalStoragePath through ServeHTTP.
+type LocalStorage struct {
+	root    *os.Root
+	baseURL string
+	key     []byte
+	maxSize int64
+	metrics *metrics.Metrics
+}
+
+// NewLocalStorage stores files under dir, creating it if needed. URLs are
+// issued under baseURL and signed with a key derived from secret; uploads
+// larger than maxSize bytes are rejected. Call latencies are recorded in m.
+func NewLocalStorage(dir, baseURL, secret string, maxSize int64, m *metrics.Metrics) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("creating storage directory: %w", err)
+	}
+	// All file access goes through root, so keys can't escape dir
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("opening storage directory: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("obscyra local storage"))
+
+	slog.Info("Successfully initialized local storage", "dir", dir)
+
+	return &LocalStorage{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/") + LocalStoragePath,
+		key:     mac.Sum(nil),
+		maxSize: maxSize,
+		metrics: m,
+	}, nil
+}
+
+// Close releases the storage directory.
+func (s *LocalStorage) Close() error {
+	return s.root.Close()
+}
+
+// GeneratePresignedPutURL creates a signed URL for uploading key.
+func (s *LocalStorage) GeneratePresignedPutURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return s.presign(ctx, http.MethodPut, key, "", expires)
+}
+
+// GeneratePresignedGetURL creates a signed URL for downloading key as
+// filename.
+func (s *LocalStorage) GeneratePresignedGetURL(ctx context.Context, key, filename string, expires time.Duration) (string, error) {
+	return s.presign(ctx, http.MethodGet, key, filename, expires)
+}
+
+// presign signs method on key until expires. The download filename is
+// signed too, so it can't be swapped for another.
+func (s *LocalStorage) presign(ctx context.Context, method, key, filename string, expires time.Duration) (string, error) {
+	_, done := s.observe(ctx, "presign_"+strings.ToLower(method))
+	if !validObjectKey(key) {
+		err := fmt.Errorf("invalid object key %q", key)
+		done(err)
+		return "", err
+	}
+	exp := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{
+		"expires":   {exp},
+		"signature": {s.sign(method, key, exp, filename)},
+	}
+	if filename != "" {
+		query.Set("filename", filename)
+	}
+	done(nil)
+	return s.baseURL + "/" + escapeObjectKey(key) + "?" + query.Encode(), nil
+}
+
+// ObjectSize returns the size of the file uploaded to key.
+func (s *LocalStorage) ObjectSize(ctx context.Context, key string) (int64, error) {
+	_, done := s.observe(ctx, "head_object")
+	info, err := s.root.Stat(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		done(nil)
+		return 0, ErrNotFound
+	}
+	done(err)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Ping checks that the storage directory is still accessible.
+func (s *LocalStorage) Ping(ctx context.Context) error {
+	_, done := s.observe(ctx, "head_bucket")
+	_, err := s.root.Stat(".")
+	done(err)
+	return err
+}
+
+// ServeHTTP handles uploads (PUT) and downloads (GET, HEAD) through presigned
+// URLs. The request path is the object key, with LocalStoragePath stripped.
+func (s *LocalStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodPut {
+		utils.ErrorResponse(w, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !validObjectKey(key) || !s.verify(method, key, r.URL.Query()) {
+		utils.ErrorResponse(w, utils.CodeInvalidSignature, "Invalid or expired signature")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(localTransferTimeout)
+	_ = rc.SetReadDeadline(deadline)
+	_ = rc.SetWriteDeadline(deadline)
+
+	if method == http.MethodPut {
+		s.serveUpload(w, r, key)
+		return
+	}
+	s.serveDownload(w, r, key)
+}
+
+func (s *LocalStorage) serveUpload(w http.ResponseWriter, r *http.Request, key string) {
+	ctx, done := s.observe(r.Context(), "put_object")
+	err := s.writeObject(key, http.MaxBytesReader(w, r.Body, s.maxSize))
+	done(err)
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		utils.ErrorResponse(w, utils.CodePayloadTooLarge, "File exceeds the upload limit")
+	case err != nil:
+		slog.ErrorContext(ctx, "Failed to store uploaded file", "error", err)
+		utils.ErrorResponse(w, utils.CodeInternal, "Failed to store file")
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// writeObject stores body under key. It is written to a temporary file
+// first, so a failed upload never leaves a partial object behind.
+func (s *LocalStorage) writeObject(key string, body io.Reader) (err error) {
+	if err := s.root.MkdirAll(path.Dir(key), 0o750); err != nil {
+		return err
+	}
+	suffix, err := utils.GenerateSecureToken(6)
+	if err != nil {
+		return err
+	}
+	tmp := key + ".upload-" + suffix
+	f, err := s.root.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = s.root.Remove(tmp)
+		}
+	}()
+
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return s.root.Rename(tmp, key)
+}
+
+func (s *LocalStorage) serveDownload(w http.ResponseWriter, r *http.Request, key string) {
+	_, done := s.observe(r.Context(), "get_object")
+	f, err := s.root.Open(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		done(nil)
+		utils.ErrorResponse(w, utils.CodeFileNotFound, "File not found")
+		return
+	}
+	if err != nil {
+		done(err)
+		utils.ErrorResponse(w, utils.CodeInternal, "Failed to read file")
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	done(err)
+	if err != nil || info.IsDir() {
+		utils.ErrorResponse(w, utils.CodeFileNotFound, "File not found")
+		return
+	}
+
+	// Files are encrypted blobs, never render them inline
+	disposition := "attachment"
+	if filename := r.URL.Query().Get("filename"); filename != "" {
+		disposition = utils.ContentDisposition(filename)
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", disposition)
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+func (s *LocalStorage) sign(method, key, expires, filename string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(method + "\n" + key + "\n" + expires + "\n" + filename))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks the signature and expiry of a presigned request
+func (s *LocalStorage) verify(method, key string, query url.Values) bool {
+	exp := query.Get("expires")
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > expUnix {
+		return false
+	}
+	expected := s.sign(method, key, exp, query.Get("filename"))
+	return hmac.Equal([]byte(query.Get("signature")), []byte(expected))
+}
+
+// validObjectKey accepts relative slash separated keys without empty, "."
+// or ".." segments
+func validObjectKey(key string) bool {
+	if key == "" || strings.ContainsRune(key, 0) || strings.Contains(key, `\`) {
+		return false
+	}
+	for seg := range strings.SplitSeq(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeObjectKey escapes each segment of key for use in a URL path
+func escapeObjectKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// observe starts a trace span for a storage operation. The returned
+// function ends it and records the call latency.
+func (s *LocalStorage) observe(ctx context.Context, operation string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "local."+operation,
+		trace.WithAttributes(
+			attribute.String("storage.system", "local"),
+			attribute.String("storage.dir", s.root.Name()),
+		),
+	)
+	return ctx, func(err error) {
+		s.metrics.ObserveStorage(operation, start, err)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,424 @@
+package repositories
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohits-web03/obscyra/internal/models"
+)
+
+// NewMemoryRepositories returns repositories that keep everything in
+// memory, for tests and local experiments. Records are copied in and out,
+// so callers can't modify stored data by accident.
+func NewMemoryRepositories() Repositories {
+	s := &memoryStore{
+		users:        map[uuid.UUID]models.User{},
+		transfers:    map[uuid.UUID]models.Transfer{},
+		files:        map[uuid.UUID][]models.File{},
+		recipients:   map[uuid.UUID][]models.Recipient{},
+		accessTokens: map[uuid.UUID]models.AccessToken{},
+		userTokens:   map[uuid.UUID]models.UserToken{},
+		identities:   map[uuid.UUID]models.Identity{},
+		auditLogs:    map[uuid.UUID]models.AuditLog{},
+	}
+	repos := Repositories{
+		Users:        memoryUsers{s},
+		Transfers:    memoryTransfers{s},
+		Recipients:   memoryRecipients{s},
+		AccessTokens: memoryAccessTokens{s},
+		UserTokens:   memoryUserTokens{s},
+		Identities:   memoryIdentities{s},
+		AuditLogs:    memoryAuditLogs{s},
+	}
+	repos.transact = func(ctx context.Context, fn func(Repositories) error) error {
+		return s.transaction(func() error { return fn(repos) })
+	}
+	return repos
+}
+
+type memoryStore struct {
+	mu         sync.RWMutex
+	users      map[uuid.UUID]models.User
+	transfers  map[uuid.UUID]models.Transfer
+	files      map[uuid.UUID][]models.File      // by transfer ID
+	recipients map[uuid.UUID][]models.Recipient // by transfer ID
+
+	accessTokens map[uuid.UUID]models.AccessToken
+	userTokens   map[uuid.UUID]models.UserToken
+	identities   map[uuid.UUID]models.Identity
+	auditLogs    map[uuid.UUID]models.AuditLog
+
+	// txMu serializes transactions
+	txMu sync.Mutex
+}
+
+// transaction runs fn, one transaction at a time. When fn fails the store
+// is restored to how it was before, which also drops changes made outside
+// the transaction meanwhile; good enough for tests.
+func (s *memoryStore) transaction(fn func() error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.RLock()
+	saved := memoryStore{
+		users:        maps.Clone(s.users),
+		transfers:    maps.Clone(s.transfers),
+		files:        maps.Clone(s.files),
+		recipients:   maps.Clone(s.recipients),
+		accessTokens: maps.Clone(s.accessTokens),
+		userTokens:   maps.Clone(s.userTokens),
+		identities:   maps.Clone(s.identities),
+		auditLogs:    maps.Clone(s.auditLogs),
+	}
+	s.mu.RUnlock()
+
+	err := fn()
+	if err != nil {
+		s.mu.Lock()
+		s.users, s.transfers, s.files, s.recipients = saved.users, saved.transfers, saved.files, saved.recipients
+		s.accessTokens, s.userTokens, s.identities, s.auditLogs = saved.accessTokens, saved.userTokens, saved.identities, saved.auditLogs
+		s.mu.Unlock()
+	}
+	return err
+}
+
+type memoryUsers struct {
+	s *memoryStore
+}
+
+func (r memoryUsers) find(match func(u *models.User) bool) (*models.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	for _, u := range r.s.users {
+		if match(&u) {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryUsers) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.ID == id })
+}
+
+// GetForUpdate doesn't lock anything; memory transactions are serialized
+func (r memoryUsers) GetForUpdate(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r memoryUsers) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.Username == username })
+}
+
+func (r memoryUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.Email == email })
+}
+
+func (r memoryUsers) GetByPublicKey(ctx context.Context, publicKey string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.PublicKey != "" && u.PublicKey == publicKey })
+}
+
+func (r memoryUsers) UsernameExists(ctx context.Context, username string) (bool, error) {
+	_, err := r.GetByUsername(ctx, username)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r memoryUsers) Create(ctx context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, u := range r.s.users {
+		if u.Username == user.Username || u.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	if _, ok := r.s.users[user.ID]; ok {
+		return ErrDuplicate
+	}
+	now := time.Now()
+	user.CreatedAt, user.UpdatedAt = now, now
+	r.s.users[user.ID] = *user
+	return nil
+}
+
+func (r memoryUsers) Update(ctx context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.users[user.ID]; !ok {
+		return ErrNotFound
+	}
+	for _, u := range r.s.users {
+		if u.ID != user.ID && (u.Username == user.Username || u.Email == user.Email) {
+			return ErrDuplicate
+		}
+	}
+	user.UpdatedAt = time.Now()
+	r.s.users[user.ID] = *user
+	return nil
+}
+
+type memoryTransfers struct {
+	s *memoryStore
+}
+
+func (r memoryTransfers) Create(ctx context.Context, transfer *models.Transfer) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.transfers {
+		if t.Token == transfer.Token {
+			return ErrDuplicate
+		}
+	}
+
+	if transfer.ID == uuid.Nil {
+		transfer.ID = uuid.New()
+	}
+	now := time.Now()
+	transfer.CreatedAt, transfer.UpdatedAt = now, now
+	for i := range transfer.Files {
+		f := &transfer.Files[i]
+		f.ID, f.TransferID = uuid.New(), transfer.ID
+		f.CreatedAt, f.UpdatedAt = now, now
+	}
+	for i := range transfer.Recipients {
+		rc := &transfer.Recipients[i]
+		rc.ID, rc.TransferID = uuid.New(), transfer.ID
+		rc.CreatedAt = now
+	}
+
+	stored := *transfer
+	stored.Files, stored.Recipients = nil, nil
+	r.s.transfers[transfer.ID] = stored
+	r.s.files[transfer.ID] = append([]models.File(nil), transfer.Files...)
+	r.s.recipients[transfer.ID] = append([]models.Recipient(nil), transfer.Recipients...)
+	return nil
+}
+
+func (r memoryTransfers) GetByToken(ctx context.Context, token string) (*models.Transfer, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	for _, t := range r.s.transfers {
+		if t.Token != token || t.Deleted {
+			continue
+		}
+		for _, f := range r.s.files[t.ID] {
+			if !f.Deleted {
+				t.Files = append(t.Files, f)
+			}
+		}
+		sort.Slice(t.Files, func(i, j int) bool { return t.Files[i].Index < t.Files[j].Index })
+		return &t, nil
+	}
+	return nil, ErrNotFound
+}
+
+type memoryRecipients struct {
+	s *memoryStore
+}
+
+func (r memoryRecipients) Get(ctx context.Context, transferID, receiverID uuid.UUID) (*models.Recipient, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	for _, rc := range r.s.recipients[transferID] {
+		if rc.ReceiverID == receiverID {
+			return &rc, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+type memoryAccessTokens struct {
+	s *memoryStore
+}
+
+func (r memoryAccessTokens) Create(ctx context.Context, token *models.AccessToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.accessTokens {
+		if t.TokenHash == token.TokenHash {
+			return ErrDuplicate
+		}
+	}
+	if token.ID == uuid.Nil {
+		token.ID = uuid.New()
+	}
+	token.CreatedAt = time.Now()
+	r.s.accessTokens[token.ID] = *token
+	return nil
+}
+
+func (r memoryAccessTokens) GetByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	for _, t := range r.s.accessTokens {
+		if t.TokenHash == tokenHash {
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryAccessTokens) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.AccessToken, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	var tokens []models.AccessToken
+	for _, t := range r.s.accessTokens {
+		if t.UserID == userID {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.After(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+func (r memoryAccessTokens) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if t, ok := r.s.accessTokens[id]; !ok || t.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.s.accessTokens, id)
+	return nil
+}
+
+func (r memoryAccessTokens) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, t := range r.s.accessTokens {
+		if t.UserID == userID {
+			delete(r.s.accessTokens, id)
+		}
+	}
+	return nil
+}
+
+func (r memoryAccessTokens) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if t, ok := r.s.accessTokens[id]; ok {
+		t.LastUsedAt = &at
+		r.s.accessTokens[id] = t
+	}
+	return nil
+}
+
+type memoryUserTokens struct {
+	s *memoryStore
+}
+
+func (r memoryUserTokens) Create(ctx context.Context, token *models.UserToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.userTokens {
+		if t.TokenHash == token.TokenHash {
+			return ErrDuplicate
+		}
+	}
+	if token.ID == uuid.Nil {
+		token.ID = uuid.New()
+	}
+	token.CreatedAt = time.Now()
+	r.s.userTokens[token.ID] = *token
+	return nil
+}
+
+func (r memoryUserTokens) Invalidate(ctx context.Context, userID uuid.UUID, purpose string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, t := range r.s.userTokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &at
+			r.s.userTokens[id] = t
+		}
+	}
+	return nil
+}
+
+func (r memoryUserTokens) Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (*models.UserToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, t := range r.s.userTokens {
+		if t.TokenHash == tokenHash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			t.UsedAt = &now
+			r.s.userTokens[id] = t
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+type memoryIdentities struct {
+	s *memoryStore
+}
+
+func (r memoryIdentities) GetBySubject(ctx context.Context, provider, subject string) (*models.Identity, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	for _, i := range r.s.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return &i, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryIdentities) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Identity, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	var identities []models.Identity
+	for _, i := range r.s.identities {
+		if i.UserID == userID {
+			identities = append(identities, i)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].CreatedAt.Before(identities[j].CreatedAt) })
+	return identities, nil
+}
+
+func (r memoryIdentities) Create(ctx context.Context, identity *models.Identity) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, i := range r.s.identities {
+		if (i.Provider == identity.Provider && i.Subject == identity.Subject) ||
+			(i.UserID == identity.UserID && i.Provider == identity.Provider) {
+			return ErrDuplicate
+		}
+	}
+	if identity.ID == uuid.Nil {
+		identity.ID = uuid.New()
+	}
+	identity.CreatedAt = time.Now()
+	r.s.identities[identity.ID] = *identity
+	return nil
+}
+
+func (r memoryIdentities) Delete(ctx context.Context, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.identities, id)
+	return nil
+}
+
+type memoryAuditLogs struct {
+	s *memoryStore
+}
+
+func (r memoryAuditLogs) Create(ctx context.Context, entry *models.AuditLog) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	entry.CreatedAt = time.Now()
+	r.s.auditLogs[entry.ID] = *entry
+	return nil
+}
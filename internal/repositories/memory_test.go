@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/models"
+)
+
+func TestMemoryTransaction(t *testing.T) {
+	errAbort := errors.New("abort")
+
+	tests := []struct {
+		name     string
+		fnErr    error
+		wantUser bool
+	}{
+		{name: "commit", fnErr: nil, wantUser: true},
+		{name: "rollback", fnErr: errAbort, wantUser: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := NewMemoryRepositories()
+
+			err := repos.Transaction(ctx, func(tx Repositories) error {
+				user := &models.User{Username: "alice", Email: "alice@example.com"}
+				if err := tx.Users.Create(ctx, user); err != nil {
+					return err
+				}
+				if err := tx.Identities.Create(ctx, &models.Identity{UserID: user.ID, Provider: "google", Subject: "1"}); err != nil {
+					return err
+				}
+				return tt.fnErr
+			})
+			if !errors.Is(err, tt.fnErr) {
+				t.Fatalf("Transaction returned %v, want %v", err, tt.fnErr)
+			}
+
+			_, err = repos.Users.GetByEmail(ctx, "alice@example.com")
+			if got := err == nil; got != tt.wantUser {
+				t.Errorf("user stored = %v, want %v", got, tt.wantUser)
+			}
+			_, err = repos.Identities.GetBySubject(ctx, "google", "1")
+			if got := err == nil; got != tt.wantUser {
+				t.Errorf("identity stored = %v, want %v", got, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestMemoryUserTokensConsume(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	token := &models.UserToken{Purpose: models.TokenPurposeResetPassword, TokenHash: "hash", ExpiresAt: now.Add(time.Hour)}
+	if err := repos.UserTokens.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		purpose string
+		hash    string
+		after   time.Duration // since now
+		wantErr error
+	}{
+		{name: "other purpose", purpose: models.TokenPurposeVerifyEmail, hash: "hash", after: 0, wantErr: ErrNotFound},
+		{name: "unknown hash", purpose: models.TokenPurposeResetPassword, hash: "other", after: 0, wantErr: ErrNotFound},
+		{name: "expired", purpose: models.TokenPurposeResetPassword, hash: "hash", after: time.Hour, wantErr: ErrNotFound},
+		{name: "valid", purpose: models.TokenPurposeResetPassword, hash: "hash", after: 30 * time.Minute},
+		{name: "already used", purpose: models.TokenPurposeResetPassword, hash: "hash", after: 30 * time.Minute, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repos.UserTokens.Consume(ctx, tt.purpose, tt.hash, now.Add(tt.after))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Consume returned %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.ID != token.ID || got.UsedAt == nil) {
+				t.Errorf("Consume returned %+v, want token %s marked used", got, token.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLockID identifies the advisory lock held while migrating, so
+// replicas starting at the same time apply each migration once
+const migrationLockID int64 = 0x6f62736379726121
+
+// Migration is a versioned schema change. Files are named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationState reports whether a migration has been applied.
+type MigrationState struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	// Unknown is set for applied versions this build has no script for,
+	// e.g. after a rollback to an older release
+	Unknown bool
+}
+
+// Migrations returns the embedded migrations for dialect in version order.
+func Migrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		base, direction, ok := cutMigrationName(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+		versionStr, label, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", name)
+		}
+
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func cutMigrationName(name string) (base, direction string, ok bool) {
+	if base, ok := strings.CutSuffix(name, ".up.sql"); ok {
+		return base, "up", true
+	}
+	if base, ok := strings.CutSuffix(name, ".down.sql"); ok {
+		return base, "down", true
+	}
+	return "", "", false
+}
+
+// MigrateUp applies every pending migration in order, each in its own
+// transaction.
+func MigrateUp(ctx context.Context, db *gorm.DB) error {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			slog.InfoContext(ctx, "Applying migration", "version", m.Version, "name", m.Name)
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first.
+func MigrateDown(ctx context.Context, db *gorm.DB, steps int) error {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return err
+	}
+	byVersion := map[int64]Migration{}
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	return withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, v := range versions[:min(steps, len(versions))] {
+			m, ok := byVersion[v]
+			if !ok {
+				return fmt.Errorf("migration %d is applied but unknown to this build", v)
+			}
+			if m.down == "" {
+				return fmt.Errorf("migration %d_%s can't be reverted, it has no down script", m.Version, m.Name)
+			}
+			slog.InfoContext(ctx, "Reverting migration", "version", m.Version, "name", m.Name)
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("reverting migration %d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigrationStatus lists the known migrations and when they were applied,
+// followed by any applied versions this build doesn't know.
+func MigrationStatus(ctx context.Context, db *gorm.DB) ([]MigrationState, error) {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := ensureMigrationsTable(ctx, conn, db.Dialector.Name()); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		state := MigrationState{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			state.AppliedAt = &at
+			delete(applied, m.Version)
+		}
+		states = append(states, state)
+	}
+	for v, at := range applied {
+		states = append(states, MigrationState{Version: v, AppliedAt: &at, Unknown: true})
+	}
+	sort.SliceStable(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+// PendingMigrations returns the migrations that haven't been applied yet.
+func PendingMigrations(ctx context.Context, db *gorm.DB) ([]MigrationState, error) {
+	states, err := MigrationStatus(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []MigrationState
+	for _, s := range states {
+		if s.AppliedAt == nil {
+			pending = append(pending, s)
+		}
+	}
+	return pending, nil
+}
+
+// withMigrationLock runs fn on a single connection holding the migration
+// advisory lock. Session level advisory locks belong to a connection, so
+// everything has to run on the same one. SQLite has no advisory locks; it
+// serves a single node and only ever lets one transaction write.
+func withMigrationLock(ctx context.Context, db *gorm.DB, fn func(conn *sql.Conn) error) (err error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	dialect := db.Dialector.Name()
+	if dialect == "sqlite" {
+		if err := ensureMigrationsTable(ctx, conn, dialect); err != nil {
+			return err
+		}
+		return fn(conn)
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	defer func() {
+		// Unlock even if ctx was cancelled, the connection goes back to the pool
+		_, unlockErr := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLockID)
+		err = errors.Join(err, unlockErr)
+	}()
+
+	if err := ensureMigrationsTable(ctx, conn, dialect); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func ensureMigrationsTable(ctx context.Context, conn *sql.Conn, dialect string) error {
+	appliedAt := "timestamptz NOT NULL DEFAULT now()"
+	if dialect == "sqlite" {
+		appliedAt = "datetime NOT NULL DEFAULT CURRENT_TIMESTAMP"
+	}
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at `+appliedAt+`
+	)`)
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations returns the applied versions and when they were applied
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
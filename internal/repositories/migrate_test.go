@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// The models of the first release, whose schema GORM AutoMigrate created
+type baselineUser struct {
+	ID                  uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Username            string    `gorm:"uniqueIndex;not null"`
+	Email               string    `gorm:"uniqueIndex;not null"`
+	Password            string    `gorm:"not null"`
+	PublicKey           string    `gorm:"type:text"`
+	EncryptedPrivateKey string    `gorm:"type:text"`
+	CreatedAt           time.Time `gorm:"autoCreateTime"`
+	UpdatedAt           time.Time `gorm:"autoUpdateTime"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineTransfer struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Token       string    `gorm:"uniqueIndex;not null"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	Deleted     bool      `gorm:"default:false"`
+	TotalSize   int64     `gorm:"not null"`
+	IsAnonymous bool
+	SenderID    *uuid.UUID          `gorm:"type:uuid;index"`
+	Files       []baselineFile      `gorm:"foreignKey:TransferID"`
+	Recipients  []baselineRecipient `gorm:"foreignKey:TransferID"`
+}
+
+func (baselineTransfer) TableName() string { return "transfers" }
+
+type baselineFile struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	TransferID  uuid.UUID `gorm:"type:uuid;index;not null"`
+	Filename    string    `gorm:"not null"`
+	Size        int64     `gorm:"not null"`
+	Path        string    `gorm:"not null"`
+	ContentType string    `gorm:"not null"`
+	Index       int       `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	Deleted     bool      `gorm:"default:false"`
+}
+
+func (baselineFile) TableName() string { return "files" }
+
+type baselineRecipient struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	TransferID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	ReceiverID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	EncryptedKey string    `gorm:"type:text;not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+func (baselineRecipient) TableName() string { return "recipients" }
+
+// TestMigrateUpUpgradesBaselineSchema migrates a database created by the
+// AutoMigrate of the first release. It needs a Postgres database to create
+// a scratch schema in, given as TEST_POSTGRES_URL.
+func TestMigrateUpUpgradesBaselineSchema(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_URL")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_URL not set")
+	}
+	ctx := context.Background()
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if err := admin.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema+",public")
+	u.RawQuery = q.Encode()
+	db, err := ConnectDatabase(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&baselineUser{}, &baselineTransfer{}, &baselineFile{}, &baselineRecipient{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&baselineUser{Username: "alice", Email: "alice@example.com", Password: "hash"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := MigrateUp(ctx, db); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	for _, column := range []string{"email_verified", "keys_reset_at", "session_version"} {
+		if !db.Migrator().HasColumn(&models.User{}, column) {
+			t.Errorf("users.%s missing after upgrade", column)
+		}
+	}
+
+	repos := NewPostgresRepositories(db)
+	user, err := repos.Users.GetByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail after upgrade: %v", err)
+	}
+	// Accounts from before email verification stay usable as recipients
+	if !user.EmailVerified || user.SessionVersion != 0 || user.KeysResetAt != nil {
+		t.Errorf("new columns of existing user = %v, %d, %v; want verified with defaults", user.EmailVerified, user.SessionVersion, user.KeysResetAt)
+	}
+
+	// Accounts created after the upgrade start unverified
+	if err := db.Create(&baselineUser{Username: "bob", Email: "bob@example.com", Password: "hash"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user, err = repos.Users.GetByEmail(ctx, "bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerified {
+		t.Error("account created after the upgrade is verified")
+	}
+}
@@ -0,0 +1,120 @@
+-- Baseline schema. Every statement is idempotent so databases created by
+-- the GORM AutoMigrate of earlier releases adopt it: tables they already have
+-- are kept and the columns added since are created below.
+
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS users (
+    id                    uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    username              text NOT NULL,
+    email                 text NOT NULL,
+    password              text NOT NULL,
+    public_key            text,
+    encrypted_private_key text,
+    email_verified        boolean NOT NULL DEFAULT false,
+    keys_reset_at         timestamptz,
+    session_version       bigint NOT NULL DEFAULT 0,
+    created_at            timestamptz,
+    updated_at            timestamptz
+);
+-- Columns added to users after the first release. Accounts that predate
+-- email verification are grandfathered in: adding the column with a true
+-- default marks the existing rows verified, new accounts then start unverified.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified boolean NOT NULL DEFAULT true;
+ALTER TABLE users ALTER COLUMN email_verified SET DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS keys_reset_at timestamptz;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS session_version bigint NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS transfers (
+    id           uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    token        text NOT NULL,
+    created_at   timestamptz,
+    updated_at   timestamptz,
+    expires_at   timestamptz NOT NULL,
+    deleted      boolean DEFAULT false,
+    total_size   bigint NOT NULL,
+    is_anonymous boolean,
+    sender_id    uuid
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_token ON transfers (token);
+CREATE INDEX IF NOT EXISTS idx_transfers_sender_id ON transfers (sender_id);
+
+CREATE TABLE IF NOT EXISTS files (
+    id           uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transfer_id  uuid NOT NULL,
+    filename     text NOT NULL,
+    size         bigint NOT NULL,
+    path         text NOT NULL,
+    content_type text NOT NULL,
+    "index"      bigint NOT NULL,
+    created_at   timestamptz,
+    updated_at   timestamptz,
+    deleted      boolean DEFAULT false,
+    CONSTRAINT fk_transfers_files FOREIGN KEY (transfer_id) REFERENCES transfers (id)
+);
+CREATE INDEX IF NOT EXISTS idx_files_transfer_id ON files (transfer_id);
+
+CREATE TABLE IF NOT EXISTS recipients (
+    id            uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transfer_id   uuid NOT NULL,
+    receiver_id   uuid NOT NULL,
+    encrypted_key text NOT NULL,
+    created_at    timestamptz,
+    CONSTRAINT fk_transfers_recipients FOREIGN KEY (transfer_id) REFERENCES transfers (id)
+);
+CREATE INDEX IF NOT EXISTS idx_recipients_transfer_id ON recipients (transfer_id);
+CREATE INDEX IF NOT EXISTS idx_recipients_receiver_id ON recipients (receiver_id);
+
+CREATE TABLE IF NOT EXISTS identities (
+    id         uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id    uuid NOT NULL,
+    provider   text NOT NULL,
+    subject    text NOT NULL,
+    email      text,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_identities_user_id ON identities (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identities_provider_subject ON identities (provider, subject);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identities_user_provider ON identities (user_id, provider);
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id         uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id    uuid NOT NULL,
+    purpose    text NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tokens_token_hash ON user_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key          text PRIMARY KEY,
+    failures     bigint NOT NULL DEFAULT 0,
+    last_failure timestamptz,
+    locked_until timestamptz,
+    updated_at   timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id         uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event      text NOT NULL,
+    user_id    uuid,
+    username   text,
+    ip         text,
+    user_agent text,
+    detail     text,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_event ON audit_logs (event);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key         text PRIMARY KEY,
+    tokens      decimal NOT NULL,
+    refilled_at timestamptz NOT NULL
+);
@@ -0,0 +1,13 @@
+CREATE TABLE access_tokens (
+    id           uuid PRIMARY KEY,
+    user_id      uuid NOT NULL,
+    name         text NOT NULL,
+    hint         text NOT NULL,
+    token_hash   text NOT NULL,
+    scopes       text NOT NULL,
+    expires_at   timestamptz,
+    last_used_at timestamptz,
+    created_at   timestamptz
+);
+CREATE INDEX idx_access_tokens_user_id ON access_tokens (user_id);
+CREATE UNIQUE INDEX idx_access_tokens_token_hash ON access_tokens (token_hash);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS rate_limit_buckets;
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS user_tokens;
+DROP TABLE IF EXISTS identities;
+DROP TABLE IF EXISTS recipients;
+DROP TABLE IF EXISTS files;
+DROP TABLE IF EXISTS transfers;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,110 @@
+-- Baseline schema. IDs are generated by the application, timestamps are
+-- stored as text in the driver's sqlite format.
+
+CREATE TABLE IF NOT EXISTS users (
+    id                    text PRIMARY KEY,
+    username              text NOT NULL,
+    email                 text NOT NULL,
+    password              text NOT NULL,
+    public_key            text,
+    encrypted_private_key text,
+    email_verified        boolean NOT NULL DEFAULT false,
+    keys_reset_at         datetime,
+    session_version       integer NOT NULL DEFAULT 0,
+    created_at            datetime,
+    updated_at            datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS transfers (
+    id           text PRIMARY KEY,
+    token        text NOT NULL,
+    created_at   datetime,
+    updated_at   datetime,
+    expires_at   datetime NOT NULL,
+    deleted      boolean DEFAULT false,
+    total_size   integer NOT NULL,
+    is_anonymous boolean,
+    sender_id    text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_token ON transfers (token);
+CREATE INDEX IF NOT EXISTS idx_transfers_sender_id ON transfers (sender_id);
+
+CREATE TABLE IF NOT EXISTS files (
+    id           text PRIMARY KEY,
+    transfer_id  text NOT NULL,
+    filename     text NOT NULL,
+    size         integer NOT NULL,
+    path         text NOT NULL,
+    content_type text NOT NULL,
+    "index"      integer NOT NULL,
+    created_at   datetime,
+    updated_at   datetime,
+    deleted      boolean DEFAULT false,
+    CONSTRAINT fk_transfers_files FOREIGN KEY (transfer_id) REFERENCES transfers (id)
+);
+CREATE INDEX IF NOT EXISTS idx_files_transfer_id ON files (transfer_id);
+
+CREATE TABLE IF NOT EXISTS recipients (
+    id            text PRIMARY KEY,
+    transfer_id   text NOT NULL,
+    receiver_id   text NOT NULL,
+    encrypted_key text NOT NULL,
+    created_at    datetime,
+    CONSTRAINT fk_transfers_recipients FOREIGN KEY (transfer_id) REFERENCES transfers (id)
+);
+CREATE INDEX IF NOT EXISTS idx_recipients_transfer_id ON recipients (transfer_id);
+CREATE INDEX IF NOT EXISTS idx_recipients_receiver_id ON recipients (receiver_id);
+
+CREATE TABLE IF NOT EXISTS identities (
+    id         text PRIMARY KEY,
+    user_id    text NOT NULL,
+    provider   text NOT NULL,
+    subject    text NOT NULL,
+    email      text,
+    created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_identities_user_id ON identities (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identities_provider_subject ON identities (provider, subject);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identities_user_provider ON identities (user_id, provider);
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id         text PRIMARY KEY,
+    user_id    text NOT NULL,
+    purpose    text NOT NULL,
+    token_hash text NOT NULL,
+    expires_at datetime NOT NULL,
+    used_at    datetime,
+    created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tokens_token_hash ON user_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key          text PRIMARY KEY,
+    failures     integer NOT NULL DEFAULT 0,
+    last_failure datetime,
+    locked_until datetime,
+    updated_at   datetime
+);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id         text PRIMARY KEY,
+    event      text NOT NULL,
+    user_id    text,
+    username   text,
+    ip         text,
+    user_agent text,
+    detail     text,
+    created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_event ON audit_logs (event);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key         text PRIMARY KEY,
+    tokens      real NOT NULL,
+    refilled_at datetime NOT NULL
+);
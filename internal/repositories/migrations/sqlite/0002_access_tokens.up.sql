@@ -0,0 +1,13 @@
+CREATE TABLE access_tokens (
+    id           text PRIMARY KEY,
+    user_id      text NOT NULL,
+    name         text NOT NULL,
+    hint         text NOT NULL,
+    token_hash   text NOT NULL,
+    scopes       text NOT NULL,
+    expires_at   datetime,
+    last_used_at datetime,
+    created_at   datetime
+);
+CREATE INDEX idx_access_tokens_user_id ON access_tokens (user_id);
+CREATE UNIQUE INDEX idx_access_tokens_token_hash ON access_tokens (token_hash);
@@ -0,0 +1,260 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohits-web03/obscyra/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewPostgresRepositories returns repositories backed by db.
+func NewPostgresRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		Users:        &postgresUsers{db: db},
+		Transfers:    &postgresTransfers{db: db},
+		Recipients:   &postgresRecipients{db: db},
+		AccessTokens: &postgresAccessTokens{db: db},
+		UserTokens:   &postgresUserTokens{db: db},
+		Identities:   &postgresIdentities{db: db},
+		AuditLogs:    &postgresAuditLogs{db: db},
+		transact: func(ctx context.Context, fn func(Repositories) error) error {
+			return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return fn(NewPostgresRepositories(tx))
+			})
+		},
+	}
+}
+
+// translateError maps GORM errors to the repository errors
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	}
+	return err
+}
+
+type postgresUsers struct {
+	db *gorm.DB
+}
+
+func (r *postgresUsers) first(ctx context.Context, query string, args ...any) (*models.User, error) {
+	return r.firstIn(r.db.WithContext(ctx), query, args...)
+}
+
+func (r *postgresUsers) firstIn(db *gorm.DB, query string, args ...any) (*models.User, error) {
+	var user models.User
+	if err := db.Where(query, args...).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *postgresUsers) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+func (r *postgresUsers) GetForUpdate(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return r.firstIn(r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}), "id = ?", id)
+}
+
+func (r *postgresUsers) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.first(ctx, "username = ?", username)
+}
+
+func (r *postgresUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.first(ctx, "email = ?", email)
+}
+
+func (r *postgresUsers) GetByPublicKey(ctx context.Context, publicKey string) (*models.User, error) {
+	// Public keys are text, so direct string comparison works
+	return r.first(ctx, "public_key = ?", publicKey)
+}
+
+func (r *postgresUsers) UsernameExists(ctx context.Context, username string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("username = ?", username).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *postgresUsers) Create(ctx context.Context, user *models.User) error {
+	return translateError(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r *postgresUsers) Update(ctx context.Context, user *models.User) error {
+	return translateError(r.db.WithContext(ctx).Save(user).Error)
+}
+
+type postgresTransfers struct {
+	db *gorm.DB
+}
+
+func (r *postgresTransfers) Create(ctx context.Context, transfer *models.Transfer) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Associations are created explicitly so their IDs come back
+		if err := tx.Omit("Files", "Recipients").Create(transfer).Error; err != nil {
+			return err
+		}
+		for i := range transfer.Files {
+			transfer.Files[i].TransferID = transfer.ID
+			if err := tx.Create(&transfer.Files[i]).Error; err != nil {
+				return err
+			}
+		}
+		for i := range transfer.Recipients {
+			transfer.Recipients[i].TransferID = transfer.ID
+			if err := tx.Create(&transfer.Recipients[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return translateError(err)
+}
+
+func (r *postgresTransfers) GetByToken(ctx context.Context, token string) (*models.Transfer, error) {
+	var transfer models.Transfer
+	err := r.db.WithContext(ctx).
+		Preload("Files", func(db *gorm.DB) *gorm.DB {
+			return db.Where("deleted = ?", false).Order(`"index"`)
+		}).
+		Where("token = ? AND deleted = ?", token, false).
+		First(&transfer).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &transfer, nil
+}
+
+type postgresRecipients struct {
+	db *gorm.DB
+}
+
+func (r *postgresRecipients) Get(ctx context.Context, transferID, receiverID uuid.UUID) (*models.Recipient, error) {
+	var recipient models.Recipient
+	err := r.db.WithContext(ctx).
+		Where("transfer_id = ? AND receiver_id = ?", transferID, receiverID).
+		First(&recipient).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &recipient, nil
+}
+
+type postgresAccessTokens struct {
+	db *gorm.DB
+}
+
+func (r *postgresAccessTokens) Create(ctx context.Context, token *models.AccessToken) error {
+	return translateError(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r *postgresAccessTokens) GetByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error) {
+	var token models.AccessToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+func (r *postgresAccessTokens) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.AccessToken, error) {
+	var tokens []models.AccessToken
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, translateError(err)
+}
+
+func (r *postgresAccessTokens) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.AccessToken{})
+	if res.Error != nil {
+		return translateError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *postgresAccessTokens) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	return translateError(r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.AccessToken{}).Error)
+}
+
+func (r *postgresAccessTokens) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&models.AccessToken{}).Where("id = ?", id).Update("last_used_at", at).Error
+	return translateError(err)
+}
+
+type postgresUserTokens struct {
+	db *gorm.DB
+}
+
+func (r *postgresUserTokens) Create(ctx context.Context, token *models.UserToken) error {
+	return translateError(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r *postgresUserTokens) Invalidate(ctx context.Context, userID uuid.UUID, purpose string, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", at).Error
+	return translateError(err)
+}
+
+func (r *postgresUserTokens) Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (*models.UserToken, error) {
+	db := r.db.WithContext(ctx)
+	// The conditional update lets only one of concurrent requests consume it
+	res := db.Model(&models.UserToken{}).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, now).
+		Update("used_at", now)
+	if res.Error != nil {
+		return nil, translateError(res.Error)
+	}
+	if res.RowsAffected != 1 {
+		return nil, ErrNotFound
+	}
+
+	var token models.UserToken
+	if err := db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+type postgresIdentities struct {
+	db *gorm.DB
+}
+
+func (r *postgresIdentities) GetBySubject(ctx context.Context, provider, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &identity, nil
+}
+
+func (r *postgresIdentities) ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Identity, error) {
+	var identities []models.Identity
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, translateError(err)
+}
+
+func (r *postgresIdentities) Create(ctx context.Context, identity *models.Identity) error {
+	return translateError(r.db.WithContext(ctx).Create(identity).Error)
+}
+
+func (r *postgresIdentities) Delete(ctx context.Context, id uuid.UUID) error {
+	return translateError(r.db.WithContext(ctx).Delete(&models.Identity{}, "id = ?", id).Error)
+}
+
+type postgresAuditLogs struct {
+	db *gorm.DB
+}
+
+func (r *postgresAuditLogs) Create(ctx context.Context, entry *models.AuditLog) error {
+	return translateError(r.db.WithContext(ctx).Create(entry).Error)
+}
@@ -4,48 +4,60 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rohits-web03/obscyra/internal/config"
+	"github.com/rohits-web03/obscyra/internal/metrics"
+	"github.com/rohits-web03/obscyra/internal/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var (
-	R2Client     *s3.Client
-	R2BucketName string
-	R2Endpoint   string
-)
+var tracer = otel.Tracer("github.com/rohits-web03/obscyra/internal/repositories")
+
+// R2Storage stores files in a Cloudflare R2 bucket through its S3 API.
+type R2Storage struct {
+	client  *s3.Client
+	bucket  string
+	metrics *metrics.Metrics
+}
 
-// InitR2 initializes the R2 client using static credentials and custom endpoint.
-func InitR2(accessKey, secretKey, accountID, bucketName, region string) error {
-	R2BucketName = bucketName
-	R2Endpoint = fmt.Sprintf("https://%s.r2.cloudflarestorage.com", accountID)
+// NewR2Storage creates an R2 client using static credentials and the
+// account's endpoint. Call latencies are recorded in m.
+func NewR2Storage(cfg config.R2Config, m *metrics.Metrics) *R2Storage {
+	endpoint := fmt.Sprintf("https://%s.r2.cloudflarestorage.com", cfg.AccountID)
 
-	cfg := aws.Config{
-		Credentials: credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
-		Region:      region,
+	awsCfg := aws.Config{
+		Credentials: credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Region:      cfg.Region,
 	}
 
-	R2Client = s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(R2Endpoint)
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
 		o.UsePathStyle = true
 	})
 
-	log.Println("Successfully initialized R2 client")
+	slog.Info("Successfully initialized R2 client", "bucket", cfg.BucketName)
 
-	return nil
+	return &R2Storage{client: client, bucket: cfg.BucketName, metrics: m}
 }
 
 // GeneratePresignedPutURL creates a presigned URL for uploading a file to R2.
-func GeneratePresignedPutURL(ctx context.Context, key string, expires time.Duration) (string, error) {
-	presigner := s3.NewPresignClient(R2Client)
+func (s *R2Storage) GeneratePresignedPutURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	ctx, done := s.observe(ctx, "presign_put")
+	presigner := s3.NewPresignClient(s.client)
 	req, err := presigner.PresignPutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(R2BucketName),
+		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	}, s3.WithPresignExpires(expires))
+	done(err)
 	if err != nil {
 		return "", err
 	}
@@ -53,33 +65,76 @@ func GeneratePresignedPutURL(ctx context.Context, key string, expires time.Durat
 }
 
 // GeneratePresignedGetURL creates a presigned URL for downloading a file from R2.
-func GeneratePresignedGetURL(ctx context.Context, key string, expires time.Duration) (string, error) {
-	presigner := s3.NewPresignClient(R2Client)
+// R2 overrides the response headers with the signed values, so the file is
+// saved as filename whatever its key.
+func (s *R2Storage) GeneratePresignedGetURL(ctx context.Context, key, filename string, expires time.Duration) (string, error) {
+	ctx, done := s.observe(ctx, "presign_get")
+	presigner := s3.NewPresignClient(s.client)
 	req, err := presigner.PresignGetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(R2BucketName),
-		Key:    aws.String(key),
+		Bucket:                     aws.String(s.bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(utils.ContentDisposition(filename)),
+		ResponseContentType:        aws.String("application/octet-stream"),
 	}, s3.WithPresignExpires(expires))
+	done(err)
 	if err != nil {
 		return "", err
 	}
 	return req.URL, nil
 }
 
-// VerifyObjectExists checks if a given object key exists in the R2 bucket.
-// Returns true if the object exists, false if not, and an error if something went wrong.
-func VerifyObjectExists(ctx context.Context, key string) (bool, error) {
-	_, err := R2Client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(R2BucketName),
+// ObjectSize returns the size of an object in the R2 bucket, or ErrNotFound
+// if it doesn't exist.
+func (s *R2Storage) ObjectSize(ctx context.Context, key string) (int64, error) {
+	ctx, done := s.observe(ctx, "head_object")
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	})
 	if err != nil {
 		var nsk *s3types.NotFound
 		if ok := errors.As(err, &nsk); ok {
 			// Object not found
-			return false, nil
+			done(nil)
+			return 0, ErrNotFound
 		}
+		done(err)
 		// Other error (e.g. auth, network)
-		return false, err
+		return 0, err
+	}
+	done(nil)
+	return aws.ToInt64(out.ContentLength), nil
+}
+
+// Ping checks that the bucket is reachable with the configured
+// credentials. HeadBucket transfers no object data, so it is cheap enough to
+// run on every readiness probe.
+func (s *R2Storage) Ping(ctx context.Context) error {
+	ctx, done := s.observe(ctx, "head_bucket")
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	done(err)
+	return err
+}
+
+// observe starts a trace span for a storage operation. The returned
+// function ends it and records the call latency.
+func (s *R2Storage) observe(ctx context.Context, operation string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "r2."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("storage.system", "r2"),
+			attribute.String("storage.bucket", s.bucket),
+		),
+	)
+	return ctx, func(err error) {
+		s.metrics.ObserveStorage(operation, start, err)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
 	}
-	return true, nil
 }
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rohits-web03/obscyra/internal/models"
+)
+
+var (
+	// ErrNotFound is returned when no record matches a lookup.
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a record violates a unique constraint.
+	ErrDuplicate = errors.New("record already exists")
+)
+
+// UserRepository stores user accounts.
+type UserRepository interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByPublicKey(ctx context.Context, publicKey string) (*models.User, error)
+	// UsernameExists reports whether the username is taken.
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	// GetForUpdate returns the user like GetByID and, inside a
+	// transaction, locks it until the transaction ends.
+	GetForUpdate(ctx context.Context, id uuid.UUID) (*models.User, error)
+	// Create stores a new user and sets its ID and timestamps.
+	Create(ctx context.Context, user *models.User) error
+	// Update saves every field of an existing user.
+	Update(ctx context.Context, user *models.User) error
+}
+
+// TransferRepository stores transfers together with their files.
+type TransferRepository interface {
+	// Create stores the transfer with its Files and Recipients in one
+	// transaction, setting their IDs.
+	Create(ctx context.Context, transfer *models.Transfer) error
+	// GetByToken returns the transfer shared under token with its files
+	// ordered by index. Deleted transfers and files are left out.
+	GetByToken(ctx context.Context, token string) (*models.Transfer, error)
+}
+
+// RecipientRepository stores the per-recipient envelopes of transfers.
+type RecipientRepository interface {
+	// Get returns the envelope of transfer for receiver.
+	Get(ctx context.Context, transferID, receiverID uuid.UUID) (*models.Recipient, error)
+}
+
+// AccessTokenRepository stores personal access tokens.
+type AccessTokenRepository interface {
+	// Create stores a new token and sets its ID.
+	Create(ctx context.Context, token *models.AccessToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.AccessToken, error)
+	// ListByUser returns the tokens of a user, newest first.
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.AccessToken, error)
+	// Delete removes a token of userID, returning ErrNotFound if the user
+	// has no token with that ID.
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	// DeleteByUser removes every token of userID.
+	DeleteByUser(ctx context.Context, userID uuid.UUID) error
+	// MarkUsed records that the token was used at.
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// UserTokenRepository stores the single-use tokens sent to users by email.
+type UserTokenRepository interface {
+	// Create stores a new token and sets its ID.
+	Create(ctx context.Context, token *models.UserToken) error
+	// Invalidate marks the unused tokens of userID for purpose as used at.
+	Invalidate(ctx context.Context, userID uuid.UUID, purpose string, at time.Time) error
+	// Consume atomically marks the unused, unexpired token with tokenHash as
+	// used at now and returns it, or returns ErrNotFound.
+	Consume(ctx context.Context, purpose, tokenHash string, now time.Time) (*models.UserToken, error)
+}
+
+// IdentityRepository stores the links between users and external identity
+// providers.
+type IdentityRepository interface {
+	// GetBySubject returns the identity with the provider's subject.
+	GetBySubject(ctx context.Context, provider, subject string) (*models.Identity, error)
+	// ListByUser returns the identities of a user, oldest first.
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]models.Identity, error)
+	// Create stores a new identity and sets its ID.
+	Create(ctx context.Context, identity *models.Identity) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// AuditLogRepository stores audit log entries.
+type AuditLogRepository interface {
+	// Create stores a new entry and sets its ID.
+	Create(ctx context.Context, entry *models.AuditLog) error
+}
+
+// Repositories groups the repositories backed by the same store.
+type Repositories struct {
+	Users        UserRepository
+	Transfers    TransferRepository
+	Recipients   RecipientRepository
+	AccessTokens AccessTokenRepository
+	UserTokens   UserTokenRepository
+	Identities   IdentityRepository
+	AuditLogs    AuditLogRepository
+
+	transact func(ctx context.Context, fn func(Repositories) error) error
+}
+
+// Transaction runs fn with repositories whose changes are committed
+// together when fn returns nil and rolled back when it returns an error.
+func (r Repositories) Transaction(ctx context.Context, fn func(tx Repositories) error) error {
+	return r.transact(ctx, fn)
+}
@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"context"
+	"time"
+)
+
+// Storage is the object store holding the encrypted files. Clients upload
+// and download directly through presigned URLs, so the server never handles
+// file contents.
+type Storage interface {
+	// GeneratePresignedPutURL creates a URL the client uploads key to.
+	GeneratePresignedPutURL(ctx context.Context, key string, expires time.Duration) (string, error)
+	// GeneratePresignedGetURL creates a URL the client downloads key from,
+	// served as an attachment named filename.
+	GeneratePresignedGetURL(ctx context.Context, key, filename string, expires time.Duration) (string, error)
+	// ObjectSize returns the size in bytes of the object uploaded to key, or
+	// ErrNotFound if nothing was uploaded.
+	ObjectSize(ctx context.Context, key string) (int64, error)
+	// Ping checks that the store is reachable.
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,68 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/rohits-web03/obscyra/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ShutdownFunc flushes buffered spans and stops the exporter.
+type ShutdownFunc func(context.Context) error
+
+// Init installs the global tracer provider and propagators. Tracing is a
+// no-op unless an exporter is configured, so the server runs offline by
+// default. The exporter endpoint, headers, sampler and resource attributes
+// are read by the SDK from the standard OTEL_* environment variables.
+func Init(ctx context.Context, cfg config.TracingConfig) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.Disabled || cfg.Exporter == "none" {
+		slog.Info("Tracing disabled")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	if cfg.Exporter != "otlp" {
+		return nil, fmt.Errorf("unsupported traces exporter %q", cfg.Exporter)
+	}
+	if cfg.Protocol != "http/protobuf" {
+		return nil, fmt.Errorf("unsupported OTLP protocol %q, only http/protobuf is supported", cfg.Protocol)
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Detectors are applied in order, so OTEL_SERVICE_NAME and
+	// OTEL_RESOURCE_ATTRIBUTES override the default service name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "obscyra")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	slog.Info("Tracing enabled", "exporter", cfg.Exporter, "protocol", cfg.Protocol)
+
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,66 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle records are dropped from memory
+const sweepInterval = time.Minute
+
+// MemoryStore keeps failure records in process memory. State is lost on
+// restart and is not shared between replicas.
+type MemoryStore struct {
+	mu         sync.Mutex
+	records    map[string]Record
+	resetAfter time.Duration
+	lastSweep  time.Time
+}
+
+// NewMemoryStore returns a store that forgets records once they are no
+// longer locked and have been idle for resetAfter, the Policy.ResetAfter of
+// the throttler using it.
+func NewMemoryStore(resetAfter time.Duration) *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record), resetAfter: resetAfter}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key], nil
+}
+
+func (s *MemoryStore) Update(_ context.Context, key string, fn func(*Record)) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(time.Now())
+
+	rec := s.records[key]
+	fn(&rec)
+	s.records[key] = rec
+	return rec, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// sweep drops records that are no longer locked and whose failures would
+// be reset anyway, so the map doesn't grow with every client ever seen.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, rec := range s.records {
+		if now.After(rec.LockedUntil) && now.Sub(rec.LastFailure) > s.resetAfter {
+			delete(s.records, key)
+		}
+	}
+}
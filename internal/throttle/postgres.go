@@ -0,0 +1,110 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresStore keeps failure records in the login_attempts table so all
+// replicas share the same view of failed logins.
+type PostgresStore struct {
+	db         *gorm.DB
+	resetAfter time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewPostgresStore returns a store that deletes rows once they are no longer
+// locked and have been idle for resetAfter, the Policy.ResetAfter of the
+// throttler using it.
+func NewPostgresStore(db *gorm.DB, resetAfter time.Duration) *PostgresStore {
+	return &PostgresStore{db: db, resetAfter: resetAfter}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (Record, error) {
+	var row models.LoginAttempt
+	err := s.db.WithContext(ctx).Where("key = ?", key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Record{}, nil
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	return toRecord(row), nil
+}
+
+func (s *PostgresStore) Update(ctx context.Context, key string, fn func(*Record)) (Record, error) {
+	if err := s.sweep(ctx, time.Now()); err != nil {
+		return Record{}, err
+	}
+
+	var rec Record
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists, then lock it for the read-modify-write
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginAttempt{Key: key}).Error; err != nil {
+			return err
+		}
+
+		var row models.LoginAttempt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+
+		rec = toRecord(row)
+		fn(&rec)
+
+		return tx.Model(&row).Updates(map[string]any{
+			"failures":     rec.Failures,
+			"last_failure": nullTime(rec.LastFailure),
+			"locked_until": nullTime(rec.LockedUntil),
+		}).Error
+	})
+	return rec, err
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+// sweep deletes the rows that are no longer locked and whose failures would
+// be reset anyway, so the table doesn't grow with every client ever seen.
+// Each replica sweeps at most once per sweepInterval.
+func (s *PostgresStore) sweep(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	return s.db.WithContext(ctx).
+		Where("(locked_until IS NULL OR locked_until < ?) AND (last_failure IS NULL OR last_failure < ?)", now, now.Add(-s.resetAfter)).
+		Delete(&models.LoginAttempt{}).Error
+}
+
+func toRecord(row models.LoginAttempt) Record {
+	rec := Record{Failures: row.Failures}
+	if row.LastFailure != nil {
+		rec.LastFailure = *row.LastFailure
+	}
+	if row.LockedUntil != nil {
+		rec.LockedUntil = *row.LockedUntil
+	}
+	return rec
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,133 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rohits-web03/obscyra/internal/config"
+	"gorm.io/gorm"
+)
+
+// Record is the failure state tracked for a single key (a username or a
+// client IP).
+type Record struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store persists failure records. Update must apply fn atomically so
+// concurrent failures for the same key are all counted.
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	Update(ctx context.Context, key string, fn func(*Record)) (Record, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Policy controls how quickly repeated failures lock a key out.
+type Policy struct {
+	FreeAttempts int           // failures allowed before lockouts start
+	BaseLockout  time.Duration // lockout after the first failure past the free attempts
+	MaxLockout   time.Duration // upper bound for the exponential backoff
+	ResetAfter   time.Duration // failures are forgotten after this long without one
+}
+
+// lockout returns the lockout duration after the given number of failures,
+// doubling with every failure past the free attempts.
+func (p Policy) lockout(failures int) time.Duration {
+	over := failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	d := p.BaseLockout
+	for i := 1; i < over && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxLockout)
+}
+
+// Throttler tracks failed attempts and applies exponential backoff with
+// temporary lockouts.
+type Throttler struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+func New(store Store, policy Policy) *Throttler {
+	return &Throttler{store: store, policy: policy, now: time.Now}
+}
+
+// NewLogins returns the throttler for failed logins, keyed by username and
+// by client IP. The memory store suits a single node; the postgres store
+// shares state across replicas.
+func NewLogins(cfg config.LoginThrottleConfig, db *gorm.DB, logger *slog.Logger) (*Throttler, error) {
+	var store Store
+	switch cfg.Store {
+	case "", "memory":
+		store = NewMemoryStore(cfg.ResetAfter)
+	case "postgres":
+		store = NewPostgresStore(db, cfg.ResetAfter)
+	default:
+		return nil, fmt.Errorf("unknown login throttle store %q", cfg.Store)
+	}
+
+	logger.Info("Login throttling initialized", "store", cfg.Store)
+	return New(store, Policy{
+		FreeAttempts: cfg.FreeAttempts,
+		BaseLockout:  cfg.BaseLockout,
+		MaxLockout:   cfg.MaxLockout,
+		ResetAfter:   cfg.ResetAfter,
+	}), nil
+}
+
+// Check returns how long the most restrictive of the keys is still locked
+// out for, or zero when an attempt is allowed.
+func (t *Throttler) Check(ctx context.Context, keys ...string) (time.Duration, error) {
+	now := t.now()
+	var wait time.Duration
+	for _, key := range keys {
+		rec, err := t.store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, rec.LockedUntil.Sub(now))
+	}
+	return wait, nil
+}
+
+// Fail records a failed attempt for every key and returns the lockout that
+// now applies, or zero when the keys are not locked out yet.
+func (t *Throttler) Fail(ctx context.Context, keys ...string) (time.Duration, error) {
+	now := t.now()
+	var wait time.Duration
+	for _, key := range keys {
+		rec, err := t.store.Update(ctx, key, func(rec *Record) {
+			if now.Sub(rec.LastFailure) > t.policy.ResetAfter {
+				rec.Failures = 0
+			}
+			rec.Failures++
+			rec.LastFailure = now
+			if d := t.policy.lockout(rec.Failures); d > 0 {
+				rec.LockedUntil = now.Add(d)
+			}
+		})
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, rec.LockedUntil.Sub(now))
+	}
+	return wait, nil
+}
+
+// Succeed clears the failures recorded for the keys.
+func (t *Throttler) Succeed(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := t.store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPolicyLockout(t *testing.T) {
+	policy := Policy{FreeAttempts: 3, BaseLockout: time.Second, MaxLockout: 10 * time.Second}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 8 * time.Second},
+		{8, 10 * time.Second}, // capped
+		{1000, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := policy.lockout(tt.failures); got != tt.want {
+			t.Errorf("lockout(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestThrottlerFail(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	policy := Policy{FreeAttempts: 2, BaseLockout: time.Minute, MaxLockout: time.Hour, ResetAfter: 48 * time.Hour}
+
+	type attempt struct {
+		after    time.Duration // since t0
+		wantWait time.Duration
+	}
+	tests := []struct {
+		name     string
+		attempts []attempt
+	}{
+		{
+			name:     "free attempts",
+			attempts: []attempt{{0, 0}, {0, 0}},
+		},
+		{
+			name:     "doubles past the free attempts",
+			attempts: []attempt{{0, 0}, {0, 0}, {0, time.Minute}, {0, 2 * time.Minute}, {0, 4 * time.Minute}},
+		},
+		{
+			name: "lockout runs from the last failure",
+			attempts: []attempt{
+				{0, 0}, {0, 0}, {0, time.Minute},
+				{30 * time.Second, 2 * time.Minute},
+			},
+		},
+		{
+			// Past 24 hours, but still within ResetAfter
+			name: "failures kept within ResetAfter",
+			attempts: []attempt{
+				{0, 0}, {0, 0},
+				{30 * time.Hour, time.Minute},
+			},
+		},
+		{
+			name: "failures forgotten after ResetAfter",
+			attempts: []attempt{
+				{0, 0}, {0, 0}, {0, time.Minute},
+				{49 * time.Hour, 0},
+				{49 * time.Hour, 0},
+				{49 * time.Hour, time.Minute},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttler := New(NewMemoryStore(policy.ResetAfter), policy)
+			for i, a := range tt.attempts {
+				now := t0.Add(a.after)
+				throttler.now = func() time.Time { return now }
+
+				wait, err := throttler.Fail(context.Background(), "alice")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if wait != a.wantWait {
+					t.Fatalf("attempt %d: wait %v, want %v", i, wait, a.wantWait)
+				}
+				if got, _ := throttler.Check(context.Background(), "alice"); got != wait {
+					t.Fatalf("attempt %d: Check returned %v, want %v", i, got, wait)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore(time.Hour)
+	store.records["idle"] = Record{Failures: 3, LastFailure: t0.Add(-2 * time.Hour)}
+	store.records["recent"] = Record{Failures: 3, LastFailure: t0.Add(-30 * time.Minute)}
+	store.records["locked"] = Record{Failures: 9, LastFailure: t0.Add(-2 * time.Hour), LockedUntil: t0.Add(time.Hour)}
+
+	store.sweep(t0)
+
+	for key, want := range map[string]bool{"idle": false, "recent": true, "locked": true} {
+		if rec, _ := store.Get(ctx, key); (rec.Failures != 0) != want {
+			t.Errorf("%s kept = %v, want %v", key, rec.Failures != 0, want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// Package tokens signs and verifies session JWTs.
+//
+// Tokens are signed with an Ed25519 (EdDSA) or P-256 (ES256) key when one is
+// configured, so other services can verify them with the public keys served
+// as a JWKS, without sharing a secret. Every key is identified by its RFC
+// 7638 thumbprint in the kid header. Without a key, tokens fall back to
+// HS256 with the server's JWT secret.
+package tokens
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rohits-web03/obscyra/internal/config"
+)
+
+// leeway tolerates clock skew between the servers issuing and verifying
+// tokens
+const leeway = 30 * time.Second
+
+// SessionClaims are the claims of a session token. The subject is the
+// user's ID.
+type SessionClaims struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	// SessionVersion must match the user's, so bumping it revokes sessions
+	SessionVersion int `json:"sv"`
+	jwt.RegisteredClaims
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    crypto.PublicKey
+}
+
+// Keys signs session tokens with the current key and verifies them against
+// every configured key.
+type Keys struct {
+	method  jwt.SigningMethod
+	signKey any    // private key, or the HMAC secret
+	kid     string // empty for HS256
+	verify  map[string]verificationKey
+	jwks    JWKS
+	methods []string
+
+	issuer   string
+	audience string
+}
+
+// New loads the keys configured in cfg. secret signs HS256 tokens when no
+// signing key is configured.
+func New(cfg config.JWTConfig, secret string) (*Keys, error) {
+	k := &Keys{
+		verify:   map[string]verificationKey{},
+		jwks:     JWKS{Keys: []JWK{}},
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+	}
+
+	if cfg.SigningKeyFile == "" {
+		if secret == "" {
+			return nil, errors.New("no JWT signing key or secret configured")
+		}
+		k.method = jwt.SigningMethodHS256
+		k.signKey = []byte(secret)
+		k.methods = []string{k.method.Alg()}
+		return k, nil
+	}
+
+	priv, err := loadPrivateKey(cfg.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if k.kid, err = k.addVerificationKey(priv.Public()); err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.SigningKeyFile, err)
+	}
+	k.method = k.verify[k.kid].method
+	k.signKey = priv
+
+	for _, file := range cfg.VerificationKeyFiles {
+		pub, err := loadPublicKey(file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := k.addVerificationKey(pub); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return k, nil
+}
+
+// KeyID returns the kid of the signing key, empty for HS256.
+func (k *Keys) KeyID() string {
+	return k.kid
+}
+
+// JWKS returns the public verification keys. It is empty for HS256, whose
+// secret can't be published.
+func (k *Keys) JWKS() JWKS {
+	return k.jwks
+}
+
+// Sign issues a token for claims valid from now for ttl, filling in the
+// issuer, audience, subject and validity claims.
+func (k *Keys) Sign(claims SessionClaims, now time.Time, ttl time.Duration) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    k.issuer,
+		Subject:   claims.UserID,
+		Audience:  jwt.ClaimStrings{k.audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+	token := jwt.NewWithClaims(k.method, &claims)
+	if k.kid != "" {
+		token.Header["kid"] = k.kid
+	}
+	return token.SignedString(k.signKey)
+}
+
+// Verify checks the signature, issuer, audience and validity period of a
+// token and returns its claims. Tokens without iss and aud, as issued by
+// earlier releases, are rejected.
+func (k *Keys) Verify(tokenString string) (*SessionClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(k.methods),
+		jwt.WithIssuer(k.issuer),
+		jwt.WithAudience(k.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(leeway),
+	)
+	claims := &SessionClaims{}
+	if _, err := parser.ParseWithClaims(tokenString, claims, k.keyFor); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// keyFor picks the key verifying token from its kid header
+func (k *Keys) keyFor(token *jwt.Token) (any, error) {
+	if k.kid == "" {
+		return k.signKey, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	vk, ok := k.verify[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	// The algorithm is bound to the key, not taken from the token
+	if token.Method.Alg() != vk.method.Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return vk.key, nil
+}
+
+// addVerificationKey accepts tokens signed by the private key of pub and
+// publishes it, returning its kid.
+func (k *Keys) addVerificationKey(pub crypto.PublicKey) (string, error) {
+	jwk, method, err := publicJWK(pub)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := k.verify[jwk.Kid]; !ok {
+		k.verify[jwk.Kid] = verificationKey{method: method, key: pub}
+		k.jwks.Keys = append(k.jwks.Keys, jwk)
+	}
+	if !slices.Contains(k.methods, method.Alg()) {
+		k.methods = append(k.methods, method.Alg())
+	}
+	return jwk.Kid, nil
+}
+
+// publicJWK describes pub as a JWK identified by its thumbprint
+func publicJWK(pub crypto.PublicKey) (JWK, jwt.SigningMethod, error) {
+	enc := base64.RawURLEncoding.EncodeToString
+	var jwk JWK
+	var method jwt.SigningMethod
+	var thumbprintInput string
+
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		jwk = JWK{Kty: "OKP", Crv: "Ed25519", X: enc(pub)}
+		method = jwt.SigningMethodEdDSA
+		// Members in lexicographic order, as RFC 7638 requires
+		thumbprintInput = fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s"}`, jwk.Crv, jwk.Kty, jwk.X)
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return JWK{}, nil, errors.New("only P-256 ECDSA keys are supported")
+		}
+		ecdhKey, err := pub.ECDH()
+		if err != nil {
+			return JWK{}, nil, err
+		}
+		// Uncompressed point: 0x04 || X || Y
+		point := ecdhKey.Bytes()
+		jwk = JWK{Kty: "EC", Crv: "P-256", X: enc(point[1:33]), Y: enc(point[33:])}
+		method = jwt.SigningMethodES256
+		thumbprintInput = fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s","y":"%s"}`, jwk.Crv, jwk.Kty, jwk.X, jwk.Y)
+	default:
+		return JWK{}, nil, fmt.Errorf("unsupported key type %T, use Ed25519 or P-256", pub)
+	}
+
+	sum := sha256.Sum256([]byte(thumbprintInput))
+	jwk.Kid = enc(sum[:])
+	jwk.Alg = method.Alg()
+	jwk.Use = "sig"
+	return jwk, method, nil
+}
+
+// loadPrivateKey reads a PKCS#8 or SEC 1 PEM private key
+func loadPrivateKey(file string) (crypto.Signer, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: expected a private key, found %s", file, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type %T", file, key)
+	}
+	return signer, nil
+}
+
+// loadPublicKey reads a PEM public key, or the public half of a private key
+func loadPublicKey(file string) (crypto.PublicKey, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != "PUBLIC KEY" {
+		priv, err := loadPrivateKey(file)
+		if err != nil {
+			return nil, err
+		}
+		return priv.Public(), nil
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return pub, nil
+}
+
+func readPEM(file string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWT key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", file)
+	}
+	return block, nil
+}
@@ -0,0 +1,191 @@
+package tokens
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rohits-web03/obscyra/internal/config"
+)
+
+// writePEM stores a private or public key as a PEM file and returns its path
+func writePEM(t *testing.T, key any) string {
+	t.Helper()
+	var block *pem.Block
+	switch key := key.(type) {
+	case crypto.Signer:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	default:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	}
+	file := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func newKeys(t *testing.T, cfg config.JWTConfig, secret string) *Keys {
+	t.Helper()
+	keys, err := New(cfg, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestSignVerify(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := config.JWTConfig{Issuer: "https://obscyra.test", Audience: "https://obscyra.test"}
+	with := func(signingKey crypto.Signer, edit func(*config.JWTConfig)) config.JWTConfig {
+		cfg := base
+		if signingKey != nil {
+			cfg.SigningKeyFile = writePEM(t, signingKey)
+		}
+		if edit != nil {
+			edit(&cfg)
+		}
+		return cfg
+	}
+	edCfg := with(edKey, nil)
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		signer   config.JWTConfig
+		secret   string
+		verifier config.JWTConfig
+		issuedAt time.Time
+		wantErr  bool
+	}{
+		{name: "EdDSA", signer: edCfg, verifier: edCfg},
+		{name: "ES256", signer: with(ecKey, nil), verifier: with(ecKey, nil)},
+		{name: "HS256", signer: base, secret: "secret", verifier: base},
+		{
+			name:     "rotated out key still accepted",
+			signer:   with(otherKey, nil),
+			verifier: with(edKey, func(c *config.JWTConfig) { c.VerificationKeyFiles = []string{writePEM(t, otherKey.Public())} }),
+		},
+		{name: "wrong issuer", signer: with(edKey, func(c *config.JWTConfig) { c.Issuer = "https://evil.test" }), verifier: edCfg, wantErr: true},
+		{name: "wrong audience", signer: with(edKey, func(c *config.JWTConfig) { c.Audience = "https://other.test" }), verifier: edCfg, wantErr: true},
+		{name: "no issuer and audience", signer: with(edKey, func(c *config.JWTConfig) { c.Issuer, c.Audience = "", "" }), verifier: edCfg, wantErr: true},
+		{name: "expired", signer: edCfg, verifier: edCfg, issuedAt: now.Add(-2 * time.Hour), wantErr: true},
+		{name: "unknown key", signer: with(otherKey, nil), verifier: edCfg, wantErr: true},
+		{name: "HS256 with another secret", signer: base, secret: "other", verifier: base, wantErr: true},
+		{name: "HS256 token for asymmetric keys", signer: base, secret: "secret", verifier: edCfg, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuedAt := tt.issuedAt
+			if issuedAt.IsZero() {
+				issuedAt = now
+			}
+			token, err := newKeys(t, tt.signer, tt.secret).Sign(SessionClaims{UserID: "user-1", Username: "alice"}, issuedAt, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := newKeys(t, tt.verifier, "secret").Verify(token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Verify accepted the token")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if claims.UserID != "user-1" || claims.Subject != "user-1" || claims.Username != "alice" {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+// TestVerifyRejectsForgedAlgorithms covers tokens whose header names an
+// algorithm other than the one bound to the key, next to a genuine one.
+func TestVerifyRejectsForgedAlgorithms(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := newKeys(t, config.JWTConfig{
+		SigningKeyFile: writePEM(t, edKey),
+		Issuer:         "https://obscyra.test",
+		Audience:       "https://obscyra.test",
+	}, "secret")
+
+	now := time.Now()
+	claims := &SessionClaims{
+		UserID: "user-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://obscyra.test",
+			Subject:   "user-1",
+			Audience:  jwt.ClaimStrings{"https://obscyra.test"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+	pub, err := x509.MarshalPKIXPublicKey(edKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		key    any
+		valid  bool
+	}{
+		{name: "EdDSA with the signing key", method: jwt.SigningMethodEdDSA, key: edKey, valid: true},
+		// The public key is known to everyone, so it must never work as an
+		// HMAC secret
+		{name: "HS256 keyed with the public key", method: jwt.SigningMethodHS256, key: pub},
+		{name: "HS256 keyed with the fallback secret", method: jwt.SigningMethodHS256, key: []byte("secret")},
+		{name: "none", method: jwt.SigningMethodNone, key: jwt.UnsafeAllowNoneSignatureType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(tt.method, claims)
+			token.Header["kid"] = keys.KeyID()
+			signed, err := token.SignedString(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := keys.Verify(signed); (err == nil) != tt.valid {
+				t.Fatalf("Verify returned %v, want valid=%v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrorCode identifies why a request failed independently of the message,
+// so clients can branch on it. Every code has a fixed HTTP status.
+type ErrorCode string
+
+// Error catalog. The comments are published in the API documentation.
+const (
+	CodeInvalidInput        ErrorCode = "INVALID_INPUT"        // The request is malformed or fails validation
+	CodeUnauthorized        ErrorCode = "UNAUTHORIZED"         // No valid session
+	CodeForbidden           ErrorCode = "FORBIDDEN"            // The session may not perform this action
+	CodeNotFound            ErrorCode = "NOT_FOUND"            // The resource doesn't exist
+	CodeMethodNotAllowed    ErrorCode = "METHOD_NOT_ALLOWED"   // The route doesn't support this HTTP method
+	CodePayloadTooLarge     ErrorCode = "PAYLOAD_TOO_LARGE"    // The request body exceeds the size limit
+	CodeRateLimited         ErrorCode = "RATE_LIMITED"         // Too many requests, retry after the RateLimit-Reset header
+	CodeInternal            ErrorCode = "INTERNAL_ERROR"       // Unexpected server error, report it with the request ID
+	CodeUnavailable         ErrorCode = "SERVICE_UNAVAILABLE"  // A dependency is down, retry later
+	CodeUsernameTaken       ErrorCode = "USERNAME_TAKEN"       // Another account uses this username
+	CodeEmailTaken          ErrorCode = "EMAIL_TAKEN"          // Another account uses this email address
+	CodeInvalidCredentials  ErrorCode = "INVALID_CREDENTIALS"  // Unknown username or wrong password
+	CodeLoginLocked         ErrorCode = "LOGIN_LOCKED"         // Too many failed logins, retry after data.retry_after seconds
+	CodeInvalidToken        ErrorCode = "INVALID_TOKEN"        // The emailed token is unknown, used or expired
+	CodeWrongPassword       ErrorCode = "WRONG_PASSWORD"       // The current password is incorrect; 400 since clients sign out on 401
+	CodePublicKeyMismatch   ErrorCode = "PUBLIC_KEY_MISMATCH"  // The public key isn't the account's, set resetKeys to replace it
+	CodeIdentityNotLinked   ErrorCode = "IDENTITY_NOT_LINKED"  // The provider isn't linked to the account
+	CodeLastSignInMethod    ErrorCode = "LAST_SIGN_IN_METHOD"  // The identity is the account's only way to sign in
+	CodeQuotaExceeded       ErrorCode = "QUOTA_EXCEEDED"       // The transfer exceeds the upload size limit
+	CodeFileNotUploaded     ErrorCode = "FILE_NOT_UPLOADED"    // A file of the transfer is missing from storage
+	CodeRecipientNotFound   ErrorCode = "RECIPIENT_NOT_FOUND"  // No account has the recipient's public key
+	CodeRecipientUnverified ErrorCode = "RECIPIENT_UNVERIFIED" // The recipient hasn't verified their email address
+	CodeTransferNotFound    ErrorCode = "TRANSFER_NOT_FOUND"   // The share link is invalid or the transfer was deleted
+	CodeTransferExpired     ErrorCode = "TRANSFER_EXPIRED"     // The transfer can no longer be downloaded
+	CodeNotARecipient       ErrorCode = "NOT_A_RECIPIENT"      // The transfer wasn't shared with this account
+	CodeRecipientKeysReset  ErrorCode = "RECIPIENT_KEYS_RESET" // The transfer was encrypted for a key pair that was since reset
+	CodeFileNotFound        ErrorCode = "FILE_NOT_FOUND"       // The transfer has no such file
+	CodeInvalidSignature    ErrorCode = "INVALID_SIGNATURE"    // The presigned URL is tampered with or expired
+	CodeInsufficientScope   ErrorCode = "INSUFFICIENT_SCOPE"   // The access token wasn't granted the scope the route requires
+	CodeSessionRequired     ErrorCode = "SESSION_REQUIRED"     // The route can't be used with an access token, sign in instead
+	CodeCSRFTokenInvalid    ErrorCode = "CSRF_TOKEN_INVALID"   // The X-CSRF-Token header is missing or doesn't match, fetch a new token
+)
+
+var codeStatus = map[ErrorCode]int{
+	CodeInvalidInput:        http.StatusBadRequest,
+	CodeUnauthorized:        http.StatusUnauthorized,
+	CodeForbidden:           http.StatusForbidden,
+	CodeNotFound:            http.StatusNotFound,
+	CodeMethodNotAllowed:    http.StatusMethodNotAllowed,
+	CodePayloadTooLarge:     http.StatusRequestEntityTooLarge,
+	CodeRateLimited:         http.StatusTooManyRequests,
+	CodeInternal:            http.StatusInternalServerError,
+	CodeUnavailable:         http.StatusServiceUnavailable,
+	CodeUsernameTaken:       http.StatusBadRequest,
+	CodeEmailTaken:          http.StatusBadRequest,
+	CodeInvalidCredentials:  http.StatusUnauthorized,
+	CodeLoginLocked:         http.StatusTooManyRequests,
+	CodeInvalidToken:        http.StatusBadRequest,
+	CodeWrongPassword:       http.StatusBadRequest,
+	CodePublicKeyMismatch:   http.StatusBadRequest,
+	CodeIdentityNotLinked:   http.StatusNotFound,
+	CodeLastSignInMethod:    http.StatusConflict,
+	CodeQuotaExceeded:       http.StatusBadRequest,
+	CodeFileNotUploaded:     http.StatusBadRequest,
+	CodeRecipientNotFound:   http.StatusBadRequest,
+	CodeRecipientUnverified: http.StatusBadRequest,
+	CodeTransferNotFound:    http.StatusNotFound,
+	CodeTransferExpired:     http.StatusGone,
+	CodeNotARecipient:       http.StatusForbidden,
+	CodeRecipientKeysReset:  http.StatusGone,
+	CodeFileNotFound:        http.StatusNotFound,
+	CodeInvalidSignature:    http.StatusForbidden,
+	CodeInsufficientScope:   http.StatusForbidden,
+	CodeSessionRequired:     http.StatusForbidden,
+	CodeCSRFTokenInvalid:    http.StatusForbidden,
+}
+
+// Status returns the HTTP status responses with code are sent with.
+func (c ErrorCode) Status() int {
+	if status, ok := codeStatus[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// codeForStatus picks a generic code for failed responses sent without one
+func codeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidInput
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	return CodeInternal
+}
+
+// ErrorBody describes why a request failed.
+type ErrorBody struct {
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+}
+
+// FieldError reports a problem with a single input field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ErrorResponse sends a failed response for code, with the status from the
+// catalog.
+func ErrorResponse(w http.ResponseWriter, code ErrorCode, message string, details ...FieldError) {
+	JSONResponse(w, code.Status(), Payload{
+		Success: false,
+		Message: message,
+		Error:   &ErrorBody{Code: code, Message: message, Details: details},
+	})
+}
+
+// ErrorMapping ties a domain error to the code and message reported for it.
+// An empty Message reports the error's own text.
+type ErrorMapping struct {
+	Err     error
+	Code    ErrorCode
+	Message string
+}
+
+// MapError returns the first mapping whose Err matches err.
+func MapError(err error, mappings []ErrorMapping) (ErrorMapping, bool) {
+	for _, m := range mappings {
+		if errors.Is(err, m.Err) {
+			if m.Message == "" {
+				m.Message = err.Error()
+			}
+			return m, true
+		}
+	}
+	return ErrorMapping{}, false
+}
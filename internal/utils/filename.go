@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxFilenameBytes is the longest filename kept, the limit of most
+// filesystems.
+const MaxFilenameBytes = 255
+
+// SanitizeFilename turns a client supplied filename into one safe to store
+// and to offer in downloads: directory components, control and invisible
+// characters and characters reserved on Windows are removed, the name is
+// NFC normalized and cut to MaxFilenameBytes, keeping its extension.
+func SanitizeFilename(name string) string {
+	name = strings.ToValidUTF8(name, "")
+	// Keep the last path element whichever separator the client uses
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	name = norm.NFC.String(name)
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case strings.ContainsRune(`<>:"|?*`, r):
+			b.WriteRune('_')
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			// Control and format characters, including bidi overrides
+			// which can disguise an extension
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	// Windows drops trailing dots and spaces; leading dots hide files
+	name = strings.Trim(b.String(), ". ")
+
+	if len(name) > MaxFilenameBytes {
+		ext := path.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = truncateUTF8(strings.TrimSuffix(name, ext), MaxFilenameBytes-len(ext)) + ext
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// ContentDisposition returns an attachment Content-Disposition header for
+// filename as described in RFC 6266: an ASCII fallback in filename and the
+// exact name in filename* for clients that support it.
+func ContentDisposition(filename string) string {
+	var fallback strings.Builder
+	for _, r := range filename {
+		switch {
+		case r == '"' || r == '\\' || r < 0x20 || r >= 0x7f:
+			fallback.WriteRune('_')
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, fallback.String(), encodeRFC5987(filename))
+}
+
+// encodeRFC5987 percent-encodes s as an ext-value, leaving only attr-chars
+// unescaped
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
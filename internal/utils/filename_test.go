@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	long := strings.Repeat("a", 300)
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "report.pdf", want: "report.pdf"},
+		{name: "unix path", in: "../../etc/passwd", want: "passwd"},
+		{name: "windows path", in: `C:\Users\alice\report.pdf`, want: "report.pdf"},
+		{name: "reserved characters", in: `a<b>c:d"e|f?g*.txt`, want: "a_b_c_d_e_f_g_.txt"},
+		{name: "control characters", in: "rep\x00or\nt.pdf", want: "report.pdf"},
+		{name: "bidi override", in: "invoice\u202efdp.exe", want: "invoicefdp.exe"},
+		{name: "zero width space", in: "re\u200bport.pdf", want: "report.pdf"},
+		{name: "unicode spaces", in: "my\u00a0file\u3000.txt", want: "my file .txt"},
+		{name: "leading and trailing dots", in: "..hidden. ", want: "hidden"},
+		{name: "NFC", in: "cafe\u0301.txt", want: "caf\u00e9.txt"},
+		{name: "invalid UTF-8", in: "a\xffb.txt", want: "ab.txt"},
+		{name: "empty", in: "", want: "file"},
+		{name: "only separators", in: "../", want: "file"},
+		{name: "only dots", in: "...", want: "file"},
+		{name: "long keeps extension", in: long + ".pdf", want: long[:MaxFilenameBytes-4] + ".pdf"},
+		{name: "long drops long extension", in: "a." + long, want: ("a." + long)[:MaxFilenameBytes]},
+		{name: "long multibyte", in: strings.Repeat("é", 200), want: strings.Repeat("é", MaxFilenameBytes/2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeFilename(tt.in)
+			if got != tt.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if len(got) > MaxFilenameBytes || !utf8.ValidString(got) {
+				t.Errorf("SanitizeFilename(%q) = %q is too long or invalid UTF-8", tt.in, got)
+			}
+			if again := SanitizeFilename(got); again != got {
+				t.Errorf("not idempotent: %q became %q", got, again)
+			}
+		})
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"report.pdf", `attachment; filename="report.pdf"; filename*=UTF-8''report.pdf`},
+		{"my report.pdf", `attachment; filename="my report.pdf"; filename*=UTF-8''my%20report.pdf`},
+		{`a"b\c.txt`, `attachment; filename="a_b_c.txt"; filename*=UTF-8''a%22b%5Cc.txt`},
+		{"café.txt", `attachment; filename="caf_.txt"; filename*=UTF-8''caf%C3%A9.txt`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := ContentDisposition(tt.filename); got != tt.want {
+				t.Errorf("ContentDisposition(%q) = %s, want %s", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
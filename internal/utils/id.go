@@ -2,7 +2,9 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 )
 
 // GenerateSecureToken creates a cryptographically secure random token.
@@ -13,3 +15,10 @@ func GenerateSecureToken(length int) (string, error) {
 	}
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
+
+// HashToken returns the form a random token is stored and looked up in. The
+// tokens have enough entropy that an unsalted SHA-256 can't be reversed.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -6,13 +6,26 @@ import (
 )
 
 type Payload struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Data    any    `json:"data,omitempty"`
+	Success   bool       `json:"success"`
+	Message   string     `json:"message"`
+	Data      any        `json:"data,omitempty"`
+	Error     *ErrorBody `json:"error,omitempty"`     // set on failed responses
+	RequestID string     `json:"requestId,omitempty"` // set on errors to correlate with server logs
 }
 
 // JSONResponse sends a JSON response with given status, success flag, and payload
 func JSONResponse(w http.ResponseWriter, status int, payload Payload) {
+	// The RequestID middleware sets the response header before any handler runs
+	if !payload.Success {
+		if payload.RequestID == "" {
+			payload.RequestID = w.Header().Get("X-Request-ID")
+		}
+		// Every failure carries an error object, even when sent without a code
+		if payload.Error == nil {
+			payload.Error = &ErrorBody{Code: codeForStatus(status), Message: payload.Message}
+		}
+		payload.Error.RequestID = payload.RequestID
+	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(payload)
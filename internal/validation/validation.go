@@ -0,0 +1,262 @@
+// Package validation checks request DTOs against rules declared in
+// `validate` struct tags, e.g.
+//
+//	Email string `json:"email" validate:"required,email,max=254"`
+//
+// Rules are separated by commas and run in order; the first failing rule of
+// a field is reported. Nested structs and slices of structs are validated
+// recursively, with paths such as files[2].filename.
+package validation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rohits-web03/obscyra/internal/utils"
+)
+
+// Password policy. bcrypt only uses the first 72 bytes of a password.
+const (
+	minPasswordLength = 8
+	maxPasswordBytes  = 72
+)
+
+// Length limits of usernames, declared as min and max rules next to the
+// username rule.
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+)
+
+// Validate returns a detail for every field of v that breaks its rules. v
+// is a struct, a slice of structs, or a pointer to either.
+func Validate(v any) []utils.FieldError {
+	var errs []utils.FieldError
+	validateValue(reflect.ValueOf(v), "", &errs)
+	return errs
+}
+
+func validateValue(v reflect.Value, path string, errs *[]utils.FieldError) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := joinPath(path, jsonName(field))
+			if msg, ok := checkField(v.Field(i), field.Tag.Get("validate")); !ok {
+				*errs = append(*errs, utils.FieldError{Field: name, Message: msg})
+				continue
+			}
+			validateValue(v.Field(i), name, errs)
+		}
+	}
+}
+
+// checkField applies the rules in tag to v and returns the message of the
+// first one that fails.
+func checkField(v reflect.Value, tag string) (string, bool) {
+	if tag == "" {
+		return "", true
+	}
+	for rule := range strings.SplitSeq(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		check, ok := rules[name]
+		if !ok {
+			panic(fmt.Sprintf("validation: unknown rule %q", name))
+		}
+		if name != "required" && v.IsZero() {
+			// Optional fields are only checked when set
+			return "", true
+		}
+		if msg, ok := check(v, arg); !ok {
+			return msg, false
+		}
+	}
+	return "", true
+}
+
+type rule func(v reflect.Value, arg string) (string, bool)
+
+var rules map[string]rule
+
+func init() {
+	rules = map[string]rule{
+		"required":  required,
+		"min":       minRule,
+		"max":       maxRule,
+		"email":     stringRule(email),
+		"username":  stringRule(username),
+		"password":  stringRule(password),
+		"publickey": stringRule(publicKey),
+		"printable": stringRule(printable),
+	}
+}
+
+func required(v reflect.Value, _ string) (string, bool) {
+	if v.Kind() == reflect.String {
+		return "is required", strings.TrimSpace(v.String()) != ""
+	}
+	if v.Kind() == reflect.Slice {
+		return "must not be empty", v.Len() > 0
+	}
+	return "is required", !v.IsZero()
+}
+
+func minRule(v reflect.Value, arg string) (string, bool) {
+	n := mustInt(arg)
+	switch v.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("must be at least %d characters", n), int64(utf8.RuneCountInString(v.String())) >= n
+	case reflect.Slice:
+		return fmt.Sprintf("must have at least %d items", n), int64(v.Len()) >= n
+	default:
+		return fmt.Sprintf("must be at least %d", n), v.Int() >= n
+	}
+}
+
+func maxRule(v reflect.Value, arg string) (string, bool) {
+	n := mustInt(arg)
+	switch v.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("must be at most %d characters", n), int64(utf8.RuneCountInString(v.String())) <= n
+	case reflect.Slice:
+		return fmt.Sprintf("must have at most %d items", n), int64(v.Len()) <= n
+	default:
+		return fmt.Sprintf("must be at most %d", n), v.Int() <= n
+	}
+}
+
+// stringRule adapts a check of string fields
+func stringRule(check func(s string) (string, bool)) rule {
+	return func(v reflect.Value, _ string) (string, bool) {
+		return check(v.String())
+	}
+}
+
+func email(s string) (string, bool) {
+	// Bare addresses only, no display names or comments
+	addr, err := mail.ParseAddress(s)
+	return "must be a valid email address", err == nil && addr.Address == s && addr.Name == ""
+}
+
+func username(s string) (string, bool) {
+	for _, r := range s {
+		if !UsernameRune(r) {
+			return "may only contain letters, digits, '_', '-' and '.'", false
+		}
+	}
+	return "", true
+}
+
+// UsernameRune reports whether r may appear in a username.
+func UsernameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.'
+}
+
+// password requires a length bcrypt can hash in full and at least two of
+// lowercase letters, uppercase letters, digits and other characters.
+func password(s string) (string, bool) {
+	if utf8.RuneCountInString(s) < minPasswordLength {
+		return fmt.Sprintf("must be at least %d characters", minPasswordLength), false
+	}
+	if len(s) > maxPasswordBytes {
+		return fmt.Sprintf("must be at most %d bytes", maxPasswordBytes), false
+	}
+	var lower, upper, digit, other int
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	if lower+upper+digit+other < 2 {
+		return "must mix at least two of lowercase letters, uppercase letters, digits and symbols", false
+	}
+	return "", true
+}
+
+// publicKey accepts the encodings clients export keys in: PEM, a JWK or
+// bare base64.
+func publicKey(s string) (string, bool) {
+	const msg = "must be a PEM, JWK or base64 encoded public key"
+	switch {
+	case strings.HasPrefix(s, "-----BEGIN"):
+		block, rest := pem.Decode([]byte(s))
+		return msg, block != nil && strings.TrimSpace(string(rest)) == ""
+	case strings.HasPrefix(s, "{"):
+		var jwk map[string]any
+		if err := json.Unmarshal([]byte(s), &jwk); err != nil {
+			return msg, false
+		}
+		_, hasType := jwk["kty"]
+		return msg, hasType
+	default:
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if _, err := enc.DecodeString(s); err == nil {
+				return msg, true
+			}
+		}
+		return msg, false
+	}
+}
+
+func printable(s string) (string, bool) {
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return "must not contain control characters", false
+		}
+	}
+	return "", true
+}
+
+func mustInt(arg string) int64 {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: invalid rule argument %q", arg))
+	}
+	return n
+}
+
+// jsonName returns the name field has in JSON documents
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
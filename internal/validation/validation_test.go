@@ -0,0 +1,120 @@
+package validation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule  string
+		value string
+		valid bool
+	}{
+		{"required", "x", true},
+		{"required", "", false},
+		{"required", "   ", false},
+
+		{"min=3", "abc", true},
+		{"min=3", "ab", false},
+		{"min=3", "äöü", true}, // characters, not bytes
+		{"max=3", "abc", true},
+		{"max=3", "abcd", false},
+		{"min=3", "", true}, // optional fields are only checked when set
+
+		{"email", "alice@example.com", true},
+		{"email", "alice", false},
+		{"email", "Alice <alice@example.com>", false},
+		{"email", "alice@example.com (work)", false},
+
+		{"username", "alice_1.b-c", true},
+		{"username", "alice smith", false},
+		{"username", "alice@example", false},
+		{"username", "jösé", false},
+
+		{"password", "Password", true},
+		{"password", "password1", true},
+		{"password", "password", false}, // one character class
+		{"password", "Pass1", false},    // too short
+		{"password", strings.Repeat("aA", 37), false},
+
+		{"publickey", "-----BEGIN PUBLIC KEY-----\nMCowBQYDK2VwAyEA\n-----END PUBLIC KEY-----\n", true},
+		{"publickey", "-----BEGIN PUBLIC KEY-----\ntruncated", false},
+		{"publickey", `{"kty":"OKP","crv":"X25519","x":"abc"}`, true},
+		{"publickey", `{"crv":"X25519"}`, false},
+		{"publickey", "MCowBQYDK2VwAyEA", true},
+		{"publickey", "not base64!", false},
+
+		{"printable", "report 2026.pdf", true},
+		{"printable", "report\n.pdf", false},
+		{"printable", "report\u202e.pdf", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule+"/"+tt.value, func(t *testing.T) {
+			msg, ok := checkField(reflect.ValueOf(tt.value), tt.rule)
+			if ok != tt.valid {
+				t.Errorf("valid = %v (%q), want %v", ok, msg, tt.valid)
+			}
+			if !ok && msg == "" {
+				t.Error("failure without a message")
+			}
+		})
+	}
+}
+
+func TestValidateReportsFieldPaths(t *testing.T) {
+	type file struct {
+		Filename string `json:"filename" validate:"required,max=5"`
+	}
+	type input struct {
+		Email    string `json:"email" validate:"required,email"`
+		Username string `json:"username" validate:"required,min=3,username"`
+		Files    []file `json:"files" validate:"required,max=2"`
+		Note     string // no rules
+	}
+
+	tests := []struct {
+		name  string
+		input input
+		want  []string // "field: message"
+	}{
+		{
+			name:  "valid",
+			input: input{Email: "alice@example.com", Username: "alice", Files: []file{{Filename: "a.txt"}}},
+		},
+		{
+			name:  "first failing rule per field",
+			input: input{Email: "alice", Username: "a b", Files: []file{{Filename: "a.txt"}}},
+			want:  []string{"email: must be a valid email address", "username: may only contain letters, digits, '_', '-' and '.'"},
+		},
+		{
+			name:  "nested slices",
+			input: input{Email: "alice@example.com", Username: "alice", Files: []file{{Filename: "a.txt"}, {Filename: ""}}},
+			want:  []string{"files[1].filename: is required"},
+		},
+		{
+			name:  "empty slice",
+			input: input{Email: "alice@example.com", Username: "alice"},
+			want:  []string{"files: must not be empty"},
+		},
+		{
+			name:  "too many items",
+			input: input{Email: "alice@example.com", Username: "alice", Files: make([]file, 3)},
+			want:  []string{"files: must have at most 2 items"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range Validate(&tt.input) {
+				got = append(got, e.Field+": "+e.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}